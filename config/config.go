@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 	"github.com/seriouspoop/gopush/model"
@@ -12,6 +15,246 @@ import (
 type Credentials struct {
 	Username string
 	Token    string
+	// Name and Email are the commit identity used with remotes of this provider.
+	Name  string
+	Email string
+}
+
+// Duration is a time.Duration stored as a human readable string ("90s", "5m")
+// in the config file.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Size is a byte count stored as a human readable string ("512KB", "50MB").
+type Size int64
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+func (s Size) MarshalText() ([]byte, error) {
+	for _, u := range sizeUnits {
+		if s != 0 && int64(s)%u.bytes == 0 {
+			return []byte(fmt.Sprintf("%d%s", int64(s)/u.bytes, u.suffix)), nil
+		}
+	}
+	return []byte("0B"), nil
+}
+
+func (s *Size) UnmarshalText(b []byte) error {
+	text := strings.ToUpper(strings.TrimSpace(string(b)))
+	if text == "" {
+		*s = 0
+		return nil
+	}
+	for _, u := range sizeUnits {
+		if num, ok := strings.CutSuffix(text, u.suffix); ok {
+			v, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid size %q", string(b))
+			}
+			*s = Size(v * u.bytes)
+			return nil
+		}
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", string(b))
+	}
+	*s = Size(v)
+	return nil
+}
+
+func (s Size) String() string {
+	b, _ := s.MarshalText()
+	return string(b)
+}
+
+// Timeout holds the per stage timeouts, zero means no timeout.
+type Timeout struct {
+	Generate Duration
+	Test     Duration
+	Pull     Duration
+	Push     Duration
+}
+
+// Output controls how gopush reports the progress of long running stages.
+type Output struct {
+	// Stream shows the output of generate and test live instead of only on failure.
+	Stream bool
+}
+
+// Generate configures the optional go generate step that runs before tests.
+type Generate struct {
+	Enabled bool
+	// Packages limits generation to these package patterns, defaults to ./...
+	Packages []string
+}
+
+// Test configures the test step.
+type Test struct {
+	// Snapshot runs generate and tests against a temporary checkout of the
+	// committed and pulled code instead of the working directory.
+	Snapshot bool
+}
+
+// Guard rejects files before they are staged unless git lfs tracks them.
+type Guard struct {
+	// MaxFileSize defaults to 50MB when unset.
+	MaxFileSize Size
+	// BinaryPatterns are globs matched against the path and the file name.
+	BinaryPatterns []string
+}
+
+// CommitType is a conventional commit type offered when writing a message.
+type CommitType struct {
+	Name        string
+	Description string
+}
+
+// Commit configures the commit message prompt.
+type Commit struct {
+	// Types defaults to the Conventional Commits types when empty.
+	Types []CommitType
+	// Scopes restricts the scope to a list, any scope is accepted when empty.
+	Scopes       []string
+	RequireScope bool
+	RequireBody  bool
+	// Template is a text/template over model.CommitMessage rendering the final message.
+	Template string
+	// CoAuthors ("Name <email>") are added as Co-authored-by trailers to every
+	// commit, gopush pair sets them.
+	CoAuthors []string
+}
+
+// Lint configures the commit message rules checked by gopush run and
+// gopush lint-commit. Types and scopes come from the Commit section.
+type Lint struct {
+	// Disabled lists rule names to skip, e.g. "subject-case".
+	Disabled []string
+	// HeaderMaxLength defaults to 100.
+	HeaderMaxLength int
+	// BodyMaxLineLength defaults to 100.
+	BodyMaxLineLength int
+	// SubjectCase is "lower", "sentence" or "any", defaults to lower.
+	SubjectCase string
+}
+
+// Ticket extracts issue keys from the branch name into commit messages.
+type Ticket struct {
+	// Pattern is a regexp matching the issue key, e.g. "[A-Z][A-Z0-9]+-[0-9]+".
+	// The first group is used when the pattern has one. Empty disables it.
+	Pattern string
+	// Placement is "footer" (Refs: KEY, default), "scope" or "prefix" (KEY subject).
+	Placement string
+	// Required warns when the branch name holds no ticket.
+	Required bool
+}
+
+// Pull configures how remote changes are brought into the current branch.
+type Pull struct {
+	// Rebase replays local commits onto the fetched upstream instead of
+	// merging, as gopush run --rebase does.
+	Rebase bool
+	// Base is the branch feature branches are brought up to date with before
+	// tests run, defaults to the branch HEAD points to on the remote.
+	Base string
+}
+
+// Mirror is a remote every push also goes to.
+type Mirror struct {
+	// Remote is the name of the git remote, repositories without it skip it.
+	Remote string
+	// Provider picks the Auth credentials, "GitHub", "GitLab", "BitBucket" or
+	// "Gitea". It is guessed from the remote url when empty.
+	Provider string
+}
+
+// Push configures pushes to the remote.
+type Push struct {
+	// Protected lists branches that are never force pushed, path.Match
+	// patterns like "release/*" are allowed.
+	Protected []string
+	// Mirrors are pushed to along with the default remote, concurrently.
+	Mirrors []Mirror
+	// BestEffort pushes without checking the remotes first and reports the
+	// ones that failed without failing the push. By default every remote is
+	// checked first and nothing is pushed when one would refuse the push, a
+	// remote failing during the push still leaves the others pushed.
+	BestEffort bool
+}
+
+// ChangelogSection titles the entries of a commit type in the changelog.
+type ChangelogSection struct {
+	// Type is a commit type, or "breaking" for breaking changes of any type.
+	Type  string
+	Title string
+}
+
+// Changelog configures gopush changelog.
+type Changelog struct {
+	// File defaults to CHANGELOG.md.
+	File string
+	// Template is a text/template over model.Changelog rendering the part of
+	// the file between the gopush markers, Keep a Changelog is used when empty.
+	Template string
+	// Sections lists the commit types in the changelog and their titles, in
+	// order, sections sharing a title are merged. Defaults to breaking changes
+	// followed by every commit type.
+	Sections []ChangelogSection
+}
+
+// Policy holds rules for the branches matching Branches. The rules of every
+// policy matching a branch add up.
+type Policy struct {
+	// Branches are names or path.Match patterns like "release/*".
+	Branches []string
+	// NoPush refuses to commit and push on these branches, gopush run offers
+	// to move the work to a new feature branch instead.
+	NoPush bool
+	// NoForce refuses force pushes, like Push.Protected.
+	NoForce bool
+	// Linear rebases the remote changes instead of merging them and refuses
+	// to push merge commits.
+	Linear bool
+	// RequireTests refuses --skip-tests and fails the run when no tests are found.
+	RequireTests bool
+}
+
+// Signing configures commit signatures. Unset fields fall back to git config:
+// commit.gpgsign, gpg.format and user.signingkey.
+type Signing struct {
+	// Enabled signs every commit, commit.gpgsign in git config enables it too.
+	Enabled bool
+	// Format is "openpgp" (default) or "ssh".
+	Format string
+	// Key is an armored keyring file for openpgp, or a private key for ssh
+	// which defaults to the gopush generated gopush_key.
+	Key string
+	// KeyID selects the openpgp key in the keyring.
+	KeyID string
 }
 
 type Config struct {
@@ -19,10 +262,32 @@ type Config struct {
 		BitBucket *Credentials
 		GitHub    *Credentials
 		GitLab    *Credentials
+		Gitea     *Credentials
 	}
 
 	DefaultRemote string
-	BranchPrefix  string
+	// Providers maps the host of self-hosted remotes to their provider, like
+	// "git.example.com" = "Gitea", when the host name does not tell it.
+	Providers    map[string]string
+	BranchPrefix string
+	// BranchTemplate names branches created with -b or gopush branch from
+	// {prefix}, {type}, {ticket} and {slug}. It defaults to
+	// "{prefix}/{type}/{ticket}-{slug}" when a prefix is set.
+	BranchTemplate string
+
+	Timeout   Timeout
+	Output    Output
+	Generate  Generate
+	Test      Test
+	Guard     Guard
+	Commit    Commit
+	Lint      Lint
+	Ticket    Ticket
+	Signing   Signing
+	Pull      Pull
+	Push      Push
+	Changelog Changelog
+	Policies  []Policy
 }
 
 func (c *Config) ProviderAuth(p model.Provider) *Credentials {
@@ -30,10 +295,34 @@ func (c *Config) ProviderAuth(p model.Provider) *Credentials {
 		model.ProviderBITBUCKET: c.Auth.BitBucket,
 		model.ProviderGITHUB:    c.Auth.GitHub,
 		model.ProviderGITLAB:    c.Auth.GitLab,
+		model.ProviderGITEA:     c.Auth.Gitea,
 	}
 	return providerToAuth[p]
 }
 
+// RemoteProvider is the provider set in Providers for the host of remote, or
+// else the one guessed from its url. c may be nil.
+func (c *Config) RemoteProvider(remote *model.Remote) model.Provider {
+	if c != nil {
+		for host, name := range c.Providers {
+			if strings.EqualFold(host, remote.Host()) {
+				return model.ParseProvider(name)
+			}
+		}
+	}
+	return remote.Provider()
+}
+
+func (c *Config) StageTimeout(s model.Stage) time.Duration {
+	stageToTimeout := map[model.Stage]Duration{
+		model.StageGenerate: c.Timeout.Generate,
+		model.StageTest:     c.Timeout.Test,
+		model.StagePull:     c.Timeout.Pull,
+		model.StagePush:     c.Timeout.Push,
+	}
+	return time.Duration(stageToTimeout[s])
+}
+
 func Read(filename, path string) (*Config, error) {
 	b, err := os.ReadFile(filepath.Join(path, filename))
 	if err != nil {
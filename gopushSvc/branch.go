@@ -0,0 +1,194 @@
+package gopushSvc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	defaultBranchTemplate = "{prefix}/{type}/{ticket}-{slug}"
+	// slugMaxLength keeps generated branch names readable.
+	slugMaxLength = 50
+)
+
+var (
+	slugSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+	repeatedDash  = regexp.MustCompile(`-{2,}`)
+	// refForbidden holds the characters git check-ref-format rejects.
+	refForbidden = regexp.MustCompile(`[\x00-\x20\x7f~^:?*\[\\]`)
+)
+
+// slugify turns a description into lowercase words joined by dashes.
+func slugify(text string) string {
+	slug := strings.Trim(slugSeparator.ReplaceAllString(strings.ToLower(text), "-"), "-")
+	if len(slug) > slugMaxLength {
+		slug = slug[:slugMaxLength]
+		if i := strings.LastIndex(slug, "-"); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	return slug
+}
+
+// checkRefFormat applies the rules of git check-ref-format --branch.
+func checkRefFormat(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", ErrBranchInvalid, name, reason)
+	}
+	switch {
+	case name == "":
+		return invalid("empty name")
+	case name == "@" || name == "HEAD":
+		return invalid("reserved name")
+	case strings.HasPrefix(name, "-"):
+		return invalid("starts with a dash")
+	case strings.HasSuffix(name, "/") || strings.HasSuffix(name, "."):
+		return invalid("ends with a slash or a dot")
+	case strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{"):
+		return invalid(`contains "..", "//" or "@{"`)
+	case refForbidden.MatchString(name):
+		return invalid(`contains a space, a control character or one of ~^:?*[\`)
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return invalid(`a part starts with "." or ends with ".lock"`)
+		}
+	}
+	return nil
+}
+
+// branchTemplate is the configured template, or the default one when only a
+// prefix is set. ok is false when branch names are used as typed.
+func (s *Svc) branchTemplate() (tmpl string, ok bool) {
+	if s.cfg == nil {
+		return "", false
+	}
+	if s.cfg.BranchTemplate != "" {
+		return s.cfg.BranchTemplate, true
+	}
+	if s.cfg.BranchPrefix != "" {
+		return defaultBranchTemplate, true
+	}
+	return "", false
+}
+
+func (s *Svc) branchPrefix() string {
+	if s.cfg == nil {
+		return ""
+	}
+	return s.cfg.BranchPrefix
+}
+
+// renderBranch fills the placeholders of tmpl, dropping the separators left
+// around empty ones.
+func renderBranch(tmpl, prefix, branchType, ticket, slug string) string {
+	name := strings.NewReplacer(
+		"{prefix}", prefix,
+		"{type}", branchType,
+		"{ticket}", ticket,
+		"{slug}", slug,
+	).Replace(tmpl)
+	components := []string{}
+	for _, component := range strings.Split(name, "/") {
+		component = strings.Trim(repeatedDash.ReplaceAllString(component, "-"), "-_.")
+		if component != "" {
+			components = append(components, component)
+		}
+	}
+	return strings.Join(components, "/")
+}
+
+func (s *Svc) isCommitType(name string) bool {
+	for _, t := range s.commitConfig().Types {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// branchName applies the branch template to a name typed with -b. A leading
+// commit type ("feat/...") and the ticket in the name fill {type} and
+// {ticket}, the rest is slugified. Names already starting with the prefix are
+// kept as typed.
+func (s *Svc) branchName(branch model.Branch) (model.Branch, error) {
+	text := strings.TrimSpace(branch.String())
+	tmpl, ok := s.branchTemplate()
+	prefix := s.branchPrefix()
+	if !ok || (prefix != "" && strings.HasPrefix(text, prefix+"/")) {
+		return model.Branch(text), checkRefFormat(text)
+	}
+
+	branchType := ""
+	if head, rest, found := strings.Cut(text, "/"); found && s.isCommitType(head) {
+		branchType, text = head, rest
+	}
+	ticket, match, err := s.findTicket(text)
+	if err != nil {
+		return "", err
+	}
+	if match != "" {
+		text = strings.Replace(text, match, " ", 1)
+	}
+	name := renderBranch(tmpl, prefix, branchType, ticket, slugify(text))
+	return model.Branch(name), checkRefFormat(name)
+}
+
+// createBranch checks out name, creating it first when it does not exist.
+func (s *Svc) createBranch(name model.Branch) error {
+	exists, err := s.SwitchBranchIfExists(name)
+	if err != nil || exists {
+		return err
+	}
+	err = s.git.CreateBranch(name)
+	if err != nil {
+		return err
+	}
+	return s.git.CheckoutBranch(name)
+}
+
+// BuildBranch asks for the type, ticket and a description of the work, then
+// creates and checks out the branch the template names from them.
+func (s *Svc) BuildBranch() error {
+	tmpl, ok := s.branchTemplate()
+	if !ok {
+		tmpl = defaultBranchTemplate
+	}
+	branchType, err := s.promptCommitType(s.commitConfig().Types)
+	if err != nil {
+		return err
+	}
+	required := s.cfg != nil && s.cfg.Ticket.Required
+	ticket, err := utils.Prompt(false, !required, "ticket")
+	if err != nil {
+		return err
+	}
+	ticket = strings.TrimSpace(ticket)
+	if found, _, err := s.findTicket(ticket); err == nil && ticket != "" && found == "" {
+		utils.Logger(utils.LOG_WARNING, fmt.Sprintf("ticket %q does not match %s", ticket, s.cfg.Ticket.Pattern))
+	}
+	description, err := utils.Prompt(false, false, "description")
+	if err != nil {
+		return err
+	}
+
+	name := renderBranch(tmpl, s.branchPrefix(), branchType, ticket, slugify(description))
+	err = checkRefFormat(name)
+	if err != nil {
+		return err
+	}
+	create, err := utils.Confirm("Create branch %s", name)
+	if err != nil || !create {
+		return err
+	}
+	err = s.createBranch(model.Branch(name))
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, "switched to new branch")
+	return nil
+}
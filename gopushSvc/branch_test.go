@@ -0,0 +1,84 @@
+package gopushSvc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Add login page", "add-login-page"},
+		{"  Fix: crash on   empty input!  ", "fix-crash-on-empty-input"},
+		{"über café", "ber-caf"},
+		{"---", ""},
+		{"this description is far too long to be kept whole in a branch name", "this-description-is-far-too-long-to-be-kept-whole"},
+		{"averyveryveryveryveryveryveryveryveryveryverylongwordthatneverends", "averyveryveryveryveryveryveryveryveryveryverylongw"},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.text); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRenderBranch(t *testing.T) {
+	tests := []struct {
+		tmpl, prefix, branchType, ticket, slug string
+		want                                   string
+	}{
+		{defaultBranchTemplate, "alice", "feat", "ABC-12", "add-login", "alice/feat/ABC-12-add-login"},
+		{defaultBranchTemplate, "alice", "feat", "", "add-login", "alice/feat/add-login"},
+		{defaultBranchTemplate, "alice", "", "ABC-12", "", "alice/ABC-12"},
+		{defaultBranchTemplate, "", "", "", "add-login", "add-login"},
+		{"{type}/{ticket}_{slug}", "", "fix", "", "crash", "fix/crash"},
+		{"{prefix}/{ticket}--{slug}", "bob", "", "", "", "bob"},
+	}
+	for _, tt := range tests {
+		got := renderBranch(tt.tmpl, tt.prefix, tt.branchType, tt.ticket, tt.slug)
+		if got != tt.want {
+			t.Errorf("renderBranch(%q, %q, %q, %q, %q) = %q, want %q", tt.tmpl, tt.prefix, tt.branchType, tt.ticket, tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRefFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"feature/login", true},
+		{"alice/feat/ABC-12-add-login", true},
+		{"v1.2", true},
+		{"", false},
+		{"HEAD", false},
+		{"@", false},
+		{"-feature", false},
+		{"feature/", false},
+		{"feature.", false},
+		{"feature..login", false},
+		{"feature//login", false},
+		{"feature@{1}", false},
+		{"feature login", false},
+		{"feature~1", false},
+		{"feature^", false},
+		{"feat:login", false},
+		{"feat?", false},
+		{"feat*", false},
+		{"feat[1]", false},
+		{`feat\login`, false},
+		{"feature/.hidden", false},
+		{"feature/login.lock", false},
+	}
+	for _, tt := range tests {
+		err := checkRefFormat(tt.name)
+		if tt.ok && err != nil {
+			t.Errorf("checkRefFormat(%q) = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrBranchInvalid) {
+			t.Errorf("checkRefFormat(%q) = %v, want ErrBranchInvalid", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package gopushSvc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	defaultChangelogFile = "CHANGELOG.md"
+	// releaseScope marks the commits gopush release makes for the changelog.
+	releaseScope = "release"
+	// changelogStart and changelogEnd enclose the part of the changelog file
+	// gopush renders, the rest of the file is left as written.
+	changelogStart = "<!-- gopush changelog start -->"
+	changelogEnd   = "<!-- gopush changelog end -->"
+)
+
+// keepAChangelogTemplate renders the changelog in the Keep a Changelog 1.1.0
+// format.
+const keepAChangelogTemplate = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/),
+and this project adheres to [Semantic Versioning](https://semver.org/spec/v2.0.0.html).
+{{- range .Releases}}
+
+## {{if .Unreleased}}[Unreleased]{{else}}[{{.Version}}] - {{.Date.Format "2006-01-02"}}{{end}}
+{{- range .Sections}}
+
+### {{.Title}}
+{{range .Entries}}
+- {{with .Scope}}**{{.}}:** {{end}}{{.Subject}}
+{{- if and .Breaking (ne .Breaking .Subject)}}: {{.Breaking}}{{end}} ({{.ShortHash}})
+{{- end}}
+{{- end}}
+{{- end}}
+`
+
+// defaultSectionTitles titles the default changelog sections, the other
+// commit types are titled with their name.
+var defaultSectionTitles = map[string]string{
+	model.BreakingSection: "Breaking Changes",
+	"feat":                "Added",
+	"fix":                 "Fixed",
+	"perf":                "Changed",
+	"refactor":            "Changed",
+	"revert":              "Reverted",
+	"docs":                "Documentation",
+	"style":               "Style",
+	"test":                "Tests",
+	"build":               "Build",
+	"ci":                  "CI",
+	"chore":               "Chores",
+}
+
+// defaultSectionAliases are older type names listed along with a default
+// type, gopush wrote ref commits before refactor was a type.
+var defaultSectionAliases = map[string][]string{
+	"refactor": {"ref"},
+}
+
+func (s *Svc) changelogConfig() config.Changelog {
+	cc := config.Changelog{}
+	if s.cfg != nil {
+		cc = s.cfg.Changelog
+	}
+	if cc.File == "" {
+		cc.File = defaultChangelogFile
+	}
+	if cc.Template == "" {
+		cc.Template = keepAChangelogTemplate
+	}
+	if len(cc.Sections) == 0 {
+		cc.Sections = []config.ChangelogSection{{Type: model.BreakingSection, Title: defaultSectionTitles[model.BreakingSection]}}
+		for _, t := range s.commitConfig().Types {
+			title, ok := defaultSectionTitles[t.Name]
+			if !ok {
+				title = t.Name
+			}
+			cc.Sections = append(cc.Sections, config.ChangelogSection{Type: t.Name, Title: title})
+			for _, alias := range defaultSectionAliases[t.Name] {
+				cc.Sections = append(cc.Sections, config.ChangelogSection{Type: alias, Title: title})
+			}
+		}
+	}
+	return cc
+}
+
+// changelogSections sorts the conventional commits into sections, breaking
+// changes going to the breaking section when there is one. Commits of types
+// without a section are left out, as are empty sections.
+func changelogSections(sections []config.ChangelogSection, commits []*model.Commit) []*model.ChangelogSection {
+	ordered := []*model.ChangelogSection{}
+	byTitle := map[string]*model.ChangelogSection{}
+	byType := map[string]*model.ChangelogSection{}
+	for _, sec := range sections {
+		cs, ok := byTitle[sec.Title]
+		if !ok {
+			cs = &model.ChangelogSection{Type: sec.Type, Title: sec.Title}
+			byTitle[sec.Title] = cs
+			ordered = append(ordered, cs)
+		}
+		byType[sec.Type] = cs
+	}
+	for _, c := range commits {
+		msg, ok := model.ParseCommitMessage(c.Message)
+		if !ok || isReleaseCommit(msg) {
+			continue
+		}
+		cs := byType[msg.Type]
+		if breaking := byType[model.BreakingSection]; msg.IsBreaking() && breaking != nil {
+			cs = breaking
+		}
+		if cs == nil {
+			continue
+		}
+		cs.Entries = append(cs.Entries, &model.ChangelogEntry{
+			Hash:     c.Hash,
+			Type:     msg.Type,
+			Scope:    msg.Scope,
+			Subject:  msg.Subject,
+			Breaking: msg.Breaking,
+			Refs:     msg.Refs,
+		})
+	}
+	out := []*model.ChangelogSection{}
+	for _, cs := range ordered {
+		if len(cs.Entries) > 0 {
+			out = append(out, cs)
+		}
+	}
+	return out
+}
+
+// buildChangelog collects the releases reachable from HEAD, newest first,
+// headed by the commits not released yet. When next is set these commits
+// are released as next, under an empty unreleased section.
+func (s *Svc) buildChangelog(next string) (*model.Changelog, error) {
+	cc := s.changelogConfig()
+	releases, err := s.releases()
+	if err != nil {
+		return nil, err
+	}
+	cl := &model.Changelog{}
+	from, previous := "", ""
+	for _, r := range releases {
+		commits, err := s.git.CommitsBetween(from, r.tag.Commit)
+		if err != nil {
+			return nil, err
+		}
+		cl.Releases = append(cl.Releases, &model.ChangelogRelease{
+			Tag:      r.tag.Name,
+			Version:  r.version.String(),
+			Previous: previous,
+			Date:     r.tag.Date,
+			Sections: changelogSections(cc.Sections, commits),
+		})
+		from, previous = r.tag.Commit, r.tag.Name
+	}
+	commits, err := s.git.CommitsBetween(from, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	unreleased := &model.ChangelogRelease{
+		Previous: previous,
+		Sections: changelogSections(cc.Sections, commits),
+	}
+	cl.Releases = append(cl.Releases, unreleased)
+	if next != "" {
+		v, _ := model.ParseVersion(next)
+		unreleased.Tag, unreleased.Version, unreleased.Date = next, v.String(), time.Now()
+		cl.Releases = append(cl.Releases, &model.ChangelogRelease{Previous: next})
+	}
+	for i, j := 0, len(cl.Releases)-1; i < j; i, j = i+1, j-1 {
+		cl.Releases[i], cl.Releases[j] = cl.Releases[j], cl.Releases[i]
+	}
+	return cl, nil
+}
+
+func isReleaseCommit(msg *model.CommitMessage) bool {
+	return msg.Type == "chore" && msg.Scope == releaseScope
+}
+
+// renderChangelog renders cl with the configured template.
+func renderChangelog(tmpl string, cl *model.Changelog) (string, error) {
+	t, err := template.New("changelog").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("changelog template: %w", err)
+	}
+	var b strings.Builder
+	err = t.Execute(&b, cl)
+	if err != nil {
+		return "", fmt.Errorf("changelog template: %w", err)
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+// changelog renders the changelog, next as in buildChangelog.
+func (s *Svc) changelog(next string) (string, error) {
+	cl, err := s.buildChangelog(next)
+	if err != nil {
+		return "", err
+	}
+	return renderChangelog(s.changelogConfig().Template, cl)
+}
+
+// spliceChangelog puts the rendered changelog between the gopush markers of
+// current, a new file holding only the markers and the changelog when current
+// is empty. A file without the markers was not written by gopush and is left
+// alone with ErrForeignChangelog.
+func spliceChangelog(current, content string) (string, error) {
+	block := changelogStart + "\n" + content + changelogEnd
+	if strings.TrimSpace(current) == "" {
+		return block + "\n", nil
+	}
+	before, rest, found := strings.Cut(current, changelogStart)
+	if !found {
+		return "", ErrForeignChangelog
+	}
+	_, after, found := strings.Cut(rest, changelogEnd)
+	if !found {
+		return "", ErrForeignChangelog
+	}
+	return before + block + after, nil
+}
+
+// writeChangelog writes the changelog, next as in buildChangelog, between the
+// gopush markers of the configured file and reports whether the file changed.
+func (s *Svc) writeChangelog(next string) (bool, error) {
+	content, err := s.changelog(next)
+	if err != nil {
+		return false, err
+	}
+	file := s.changelogConfig().File
+	current, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	updated, err := spliceChangelog(string(current), content)
+	if err != nil {
+		utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("put %s and %s around the part of %s gopush may rewrite", changelogStart, changelogEnd, file))
+		return false, fmt.Errorf("%w: %s", err, file)
+	}
+	if string(current) == updated {
+		return false, nil
+	}
+	return true, os.WriteFile(file, []byte(updated), 0644)
+}
+
+// Changelog writes the changelog of the release tags reachable from HEAD,
+// and of the commits since the last one, to the configured file. The part
+// between the gopush markers is rendered again from the history each time,
+// the rest of the file is kept. With dryRun it is only printed.
+func (s *Svc) Changelog(dryRun bool) error {
+	if dryRun {
+		content, err := s.changelog("")
+		if err != nil {
+			return err
+		}
+		fmt.Print(content)
+		return nil
+	}
+	changed, err := s.writeChangelog("")
+	if err != nil {
+		return err
+	}
+	file := s.changelogConfig().File
+	if !changed {
+		utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s is up to date", file))
+		return nil
+	}
+	utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s written", file))
+	return nil
+}
+
+// commitChangelog writes the changelog releasing next and commits it alone.
+func (s *Svc) commitChangelog(next string, author, committer *model.Identity) error {
+	staged, err := s.stagedChanges()
+	if err != nil {
+		return err
+	}
+	if staged {
+		return ErrStagedChanges
+	}
+	changed, err := s.writeChangelog(next)
+	if err != nil || !changed {
+		return err
+	}
+	file := s.changelogConfig().File
+	err = s.git.Add([]string{file})
+	if err != nil {
+		return err
+	}
+	msg := &model.CommitMessage{Type: "chore", Scope: releaseScope, Subject: next}
+	err = s.git.Commit(msg.Header()+"\n", author, committer, false)
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s committed", file))
+	return nil
+}
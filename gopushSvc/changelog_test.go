@@ -0,0 +1,65 @@
+package gopushSvc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/seriouspoop/gopush/model"
+)
+
+func TestChangelogSections(t *testing.T) {
+	s := &Svc{}
+	commits := []*model.Commit{
+		{Hash: "1", Message: "feat: add thing"},
+		{Hash: "2", Message: "ref: move thing"},
+		{Hash: "3", Message: "refactor: split thing"},
+		{Hash: "4", Message: "fix!: drop old flag"},
+		{Hash: "5", Message: "chore(release): v1.0.0"},
+		{Hash: "6", Message: "not conventional"},
+	}
+	got := map[string]int{}
+	for _, cs := range changelogSections(s.changelogConfig().Sections, commits) {
+		got[cs.Title] = len(cs.Entries)
+	}
+	want := map[string]int{"Added": 1, "Changed": 2, "Breaking Changes": 1}
+	if len(got) != len(want) {
+		t.Fatalf("sections = %v, want %v", got, want)
+	}
+	for title, n := range want {
+		if got[title] != n {
+			t.Errorf("%s has %d entries, want %d", title, got[title], n)
+		}
+	}
+}
+
+func TestSpliceChangelog(t *testing.T) {
+	const content = "# Changelog\n\n## [1.0.0]\n"
+	block := changelogStart + "\n" + content + changelogEnd
+	tests := []struct {
+		name    string
+		current string
+		want    string
+		wantErr error
+	}{
+		{"new file", "", block + "\n", nil},
+		{"generated file", changelogStart + "\n# Changelog\n" + changelogEnd + "\n", block + "\n", nil},
+		{
+			name:    "hand written parts",
+			current: "Notes on top.\n\n" + changelogStart + "\nold\n" + changelogEnd + "\n\n## 0.1.0 by hand\n",
+			want:    "Notes on top.\n\n" + block + "\n\n## 0.1.0 by hand\n",
+		},
+		{"no markers", "# My changelog\n", "", ErrForeignChangelog},
+		{"no end marker", changelogStart + "\n# Changelog\n", "", ErrForeignChangelog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := spliceChangelog(tt.current, content)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("spliceChangelog() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("spliceChangelog() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
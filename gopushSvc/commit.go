@@ -0,0 +1,241 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	noScope = "(none)"
+
+	editMessage  = "edit in $EDITOR"
+	writeAgain   = "write it again"
+	abortMessage = "abort the commit"
+)
+
+// defaultCommitTemplate renders a Conventional Commits 1.0.0 message.
+const defaultCommitTemplate = `{{.Type}}{{with .Scope}}({{.}}){{end}}{{if .IsBreaking}}!{{end}}: {{.Subject}}
+{{- with .Body}}
+
+{{.}}
+{{- end}}
+{{- if or .IsBreaking .Refs .Footers}}
+{{with .Breaking}}
+BREAKING CHANGE: {{.}}
+{{- end}}
+{{- range .Refs}}
+Refs: {{.}}
+{{- end}}
+{{- range .Footers}}
+{{.Token}}: {{.Value}}
+{{- end}}
+{{- end}}
+`
+
+var defaultCommitTypes = []config.CommitType{
+	{Name: "feat", Description: "a new feature"},
+	{Name: "fix", Description: "a bug fix"},
+	{Name: "docs", Description: "documentation only changes"},
+	{Name: "style", Description: "formatting, no code change"},
+	{Name: "refactor", Description: "code change that neither fixes a bug nor adds a feature"},
+	{Name: "perf", Description: "a performance improvement"},
+	{Name: "test", Description: "adding or fixing tests"},
+	{Name: "build", Description: "build system or dependency changes"},
+	{Name: "ci", Description: "ci configuration changes"},
+	{Name: "chore", Description: "other changes that don't modify src or test files"},
+	{Name: "revert", Description: "reverts a previous commit"},
+}
+
+// appendTrailer adds "token: value" to the trailer block of message, starting
+// one when the message has none.
+func appendTrailer(message, token, value string) string {
+	message = strings.TrimRight(message, "\n")
+	lines := strings.Split(message, "\n")
+	if len(lines) > 2 && model.IsTrailer(lines[len(lines)-1]) {
+		return fmt.Sprintf("%s\n%s: %s", message, token, value)
+	}
+	return fmt.Sprintf("%s\n\n%s: %s", message, token, value)
+}
+
+func (s *Svc) commitConfig() config.Commit {
+	cc := config.Commit{}
+	if s.cfg != nil {
+		cc = s.cfg.Commit
+	}
+	if len(cc.Types) == 0 {
+		cc.Types = defaultCommitTypes
+	}
+	if cc.Template == "" {
+		cc.Template = defaultCommitTemplate
+	}
+	return cc
+}
+
+// renderCommitMsg renders msg with the configured template.
+func renderCommitMsg(tmpl string, msg *model.CommitMessage) (string, error) {
+	t, err := template.New("commit").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("commit template: %w", err)
+	}
+	var b strings.Builder
+	err = t.Execute(&b, msg)
+	if err != nil {
+		return "", fmt.Errorf("commit template: %w", err)
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+func splitRefs(refs string) []string {
+	out := []string{}
+	for _, ref := range strings.Split(refs, ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+func (s *Svc) promptCommitType(types []config.CommitType) (string, error) {
+	items := make([]string, len(types))
+	for i, t := range types {
+		items[i] = t.Name
+		if t.Description != "" {
+			items[i] = fmt.Sprintf("%s: %s", t.Name, t.Description)
+		}
+	}
+	item, err := utils.Select("Select commit type", items)
+	if err != nil {
+		return "", err
+	}
+	name, _, _ := strings.Cut(item, ":")
+	return name, nil
+}
+
+func (s *Svc) promptScope(cc config.Commit) (string, error) {
+	if len(cc.Scopes) == 0 {
+		scope, err := utils.Prompt(false, !cc.RequireScope, "scope")
+		return strings.TrimSpace(scope), err
+	}
+	items := cc.Scopes
+	if !cc.RequireScope {
+		items = append([]string{noScope}, cc.Scopes...)
+	}
+	scope, err := utils.Select("Select scope", items)
+	if err != nil || scope == noScope {
+		return "", err
+	}
+	return scope, nil
+}
+
+// promptCommitMsg collects the parts of a conventional commit message.
+func (s *Svc) promptCommitMsg(cc config.Commit) (*model.CommitMessage, error) {
+	msg := &model.CommitMessage{}
+	var err error
+
+	msg.Type, err = s.promptCommitType(cc.Types)
+	if err != nil {
+		return nil, err
+	}
+	msg.Scope, err = s.promptScope(cc)
+	if err != nil {
+		return nil, err
+	}
+	msg.Subject, err = utils.Prompt(false, false, "subject")
+	if err != nil {
+		return nil, err
+	}
+	msg.Subject = strings.TrimSpace(msg.Subject)
+	msg.Body, err = utils.Prompt(false, !cc.RequireBody, "body")
+	if err != nil {
+		return nil, err
+	}
+	msg.Body = strings.TrimSpace(msg.Body)
+
+	breaking, err := utils.Confirm("breaking change")
+	if err != nil {
+		return nil, err
+	}
+	if breaking {
+		msg.Breaking, err = utils.Prompt(false, false, "breaking change description")
+		if err != nil {
+			return nil, err
+		}
+		msg.Breaking = strings.TrimSpace(msg.Breaking)
+	}
+
+	refs, err := utils.Prompt(false, true, "issue refs (comma separated)")
+	if err != nil {
+		return nil, err
+	}
+	msg.Refs = splitRefs(refs)
+	return msg, nil
+}
+
+func (s *Svc) generateCommitMsg(ticket string) (string, error) {
+	cc := s.commitConfig()
+	msg, err := s.promptCommitMsg(cc)
+	if err != nil {
+		return "", err
+	}
+	s.applyTicket(msg, ticket)
+	return renderCommitMsg(cc.Template, msg)
+}
+
+// fixCommitMsg lets the user edit message until it passes the lint rules,
+// write another one, returned empty, or give up with ErrCommitLint.
+func (s *Svc) fixCommitMsg(ctx context.Context, message string) (string, error) {
+	for errors.Is(s.LintCommitMsg(message), ErrCommitLint) {
+		choice, err := utils.Select("Commit message breaks the rules", []string{editMessage, writeAgain, abortMessage})
+		if err != nil {
+			return "", err
+		}
+		switch choice {
+		case editMessage:
+			message, err = s.editCommitMsg(ctx, message)
+			if err != nil {
+				return "", err
+			}
+		case writeAgain:
+			return "", nil
+		default:
+			return "", ErrCommitLint
+		}
+	}
+	return message, nil
+}
+
+// editCommitMsg opens message in the editor with the broken rules as comments.
+func (s *Svc) editCommitMsg(ctx context.Context, message string) (string, error) {
+	f, err := os.CreateTemp("", "COMMIT_EDITMSG-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(message, "\n") + "\n\n")
+	for _, p := range s.lintCommitMsg(message) {
+		fmt.Fprintf(&b, "# %s\n", p)
+	}
+	_, err = f.WriteString(b.String())
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	err = s.bash.OpenEditor(ctx, f.Name())
+	if err != nil {
+		return "", err
+	}
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return cleanCommitMsg(string(edited)), nil
+}
@@ -1,6 +1,7 @@
 package gopushSvc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -115,7 +116,7 @@ func (s *Svc) SetRemoteHTTPAuth() error {
 		return err
 	}
 	utils.Logger(utils.LOG_INFO, "Gathering auth details...")
-	provider := remoteDetails.Provider()
+	provider := cfg.RemoteProvider(remoteDetails)
 	if cfg.ProviderAuth(provider) == nil {
 		utils.Logger(utils.LOG_FAILURE, "auth credentials not found")
 		username, token, err := s.authInput(provider.String())
@@ -133,6 +134,8 @@ func (s *Svc) SetRemoteHTTPAuth() error {
 			cfg.Auth.BitBucket = cred
 		case model.ProviderGITLAB:
 			cfg.Auth.GitLab = cred
+		case model.ProviderGITEA:
+			cfg.Auth.Gitea = cred
 		}
 		utils.Logger(utils.LOG_SUCCESS, "auth generated")
 	} else {
@@ -158,7 +161,7 @@ func (s *Svc) authInput(provider string) (string, string, error) {
 	return username, token, nil
 }
 
-func (s *Svc) SetRemoteSSHAuth() error {
+func (s *Svc) SetRemoteSSHAuth(ctx context.Context) error {
 	remoteDetails, err := s.git.GetRemoteDetails()
 	if err != nil {
 		return err
@@ -184,13 +187,19 @@ func (s *Svc) SetRemoteSSHAuth() error {
 		if err != nil {
 			return err
 		}
-		err = s.bash.GenerateSSHKey(gopushDirPath, keyName, mail, passphrase)
+		keygenCtx, cancel := s.stageContext(ctx, model.StageKeygen)
+		defer cancel()
+		err = s.bash.GenerateSSHKey(keygenCtx, gopushDirPath, keyName, mail, passphrase)
 		if err != nil {
-			return err
+			return stageError(keygenCtx, model.StageKeygen, err)
 		}
 		utils.Logger(utils.LOG_SUCCESS, "keys generated")
 		// add keys to known hosts
-		hostCode := fmt.Sprintf("Host %s\n  AddKeysToAgent yes\n  IdentityFile \"%s\"", remoteDetails.Provider().HostURL(), filepath.Join(gopushDirPath, keyName))
+		host := remoteDetails.Host()
+		if host == "" {
+			return fmt.Errorf("%w: no host in %s", ErrInvalidAuthMethod, remoteDetails.Url)
+		}
+		hostCode := fmt.Sprintf("Host %s\n  AddKeysToAgent yes\n  IdentityFile \"%s\"", host, filepath.Join(gopushDirPath, keyName))
 
 		fileContent, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".ssh", "config"))
 		if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -206,7 +215,7 @@ func (s *Svc) SetRemoteSSHAuth() error {
 			return err
 		}
 		utils.Logger(utils.LOG_SUCCESS, "key added to host")
-		message := fmt.Sprintf("copy contents of %s.pub and upload the keys on %s", filepath.Join(gopushDirPath, keyName), remoteDetails.Provider().String())
+		message := fmt.Sprintf("copy contents of %s.pub and upload the keys on %s", filepath.Join(gopushDirPath, keyName), host)
 		utils.Logger(utils.LOG_STRICT_INFO, message)
 		return ErrWaitExit
 	} else {
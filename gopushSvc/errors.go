@@ -8,6 +8,7 @@ var (
 	ErrBranchInvalid        = errors.New("invalid branch")
 	ErrBranchAlreadyExist   = errors.New("branch already exist")
 	ErrTestsFailed          = errors.New("tests failed")
+	ErrGenerateFailed       = errors.New("go generate failed")
 	ErrRemoteNotLoaded      = errors.New("remote not loaded")
 	ErrRemoteNotFound       = errors.New("no remotes found")
 	ErrRemoteAlreadyExists  = errors.New("remote already exists")
@@ -23,4 +24,39 @@ var (
 	ErrAuthLoadFailed       = errors.New("failed to load auth")
 	ErrAlreadyUpToDate      = errors.New("already up to date")
 	ErrRemoteBranchNotFound = errors.New("remote branch not found")
+	ErrSecretsFound         = errors.New("secrets found in staged changes")
+	ErrFileTooLarge         = errors.New("large or binary files rejected")
+	ErrCommitLint           = errors.New("commit message does not follow the rules")
+	ErrSignKeyNotFound      = errors.New("signing key not found")
+	ErrSignFormat           = errors.New("unsupported signing format")
+	ErrIdentityNotFound     = errors.New("commit identity not found, set user.name and user.email")
+	ErrInvalidIdentity      = errors.New("co-author must look like Name <email>")
+	ErrCommitNotFound       = errors.New("commit not found")
+	ErrAutosquashFailed     = errors.New("fixup could not be squashed")
+	ErrRewritePushed        = errors.New("commit is already on the remote, use --force-with-lease to rewrite it")
+	ErrDetachedHead         = errors.New("not on a branch")
+	ErrRebaseConflict       = errors.New("rebase stopped on conflicts")
+	ErrRebaseInProgress     = errors.New("a rebase is in progress, run gopush rebase --continue or --abort")
+	ErrNoRebase             = errors.New("no rebase in progress")
+	ErrConflictsRemain      = errors.New("conflict markers left in")
+	ErrMergeConflict        = errors.New("merge stopped on conflicts")
+	ErrMergeInProgress      = errors.New("a merge is in progress")
+	ErrUnrelatedHistories   = errors.New("refusing to merge unrelated histories")
+	ErrNoMerge              = errors.New("no merge or rebase in progress")
+	ErrNothingToAbort       = errors.New("nothing to abort")
+	ErrPushFailed           = errors.New("push failed")
+	ErrPushRejected         = errors.New("push rejected, the remote branch has commits that are not pulled")
+	ErrStaleLease           = errors.New("push rejected, the remote branch moved since it was last fetched")
+	ErrProtectedBranch      = errors.New("force pushing a protected branch is not allowed")
+	ErrPushNotAllowed       = errors.New("branch does not take direct pushes, work on a feature branch")
+	ErrTestsRequired        = errors.New("branch policy requires tests")
+	ErrTagExists            = errors.New("tag already exists")
+	ErrNothingToRelease     = errors.New("no feat, fix or breaking commits to release")
+	ErrInvalidBump          = errors.New("bump must be major, minor or patch")
+	ErrStagedChanges        = errors.New("staged changes would be committed with the changelog, commit or unstage them first")
+	ErrForeignChangelog     = errors.New("changelog has no gopush markers, it was not written by gopush")
+	ErrNonLinear            = errors.New("branch requires a linear history, found merge commit")
+	ErrNoTerminal           = errors.New("file selection needs a terminal, use --all")
+	ErrInterrupted          = errors.New("interrupted")
+	ErrStageTimeout         = errors.New("timed out")
 )
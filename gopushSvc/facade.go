@@ -1,6 +1,8 @@
 package gopushSvc
 
 import (
+	"context"
+	"io"
 	"os"
 
 	"github.com/seriouspoop/gopush/config"
@@ -16,21 +18,69 @@ type gitHelper interface {
 	AddRemote(remote *model.Remote) error
 	LoadRemote(remoteName string) error
 	GetRemoteDetails() (*model.Remote, error)
+	Remote(name string) (*model.Remote, error)
+	Upstream(branch model.Branch) (*model.Upstream, error)
+	SetUpstream(branch model.Branch, upstream *model.Upstream) error
+	UnpushedMerges(remote string, branches []model.Branch) ([]*model.Commit, error)
+	RemoteHead(ctx context.Context, remote *model.Remote, auth *config.Credentials) (model.Branch, error)
+	AheadBehind(branch model.Branch, upstream *model.Upstream) (ahead, behind int, err error)
 	ChangeOccured() (bool, error)
-	AddThenCommit(commitMsg string) error
-	Pull(remote *model.Remote, branch model.Branch, auth *config.Credentials, force bool) error
-	Push(remote *model.Remote, branch model.Branch, auth *config.Credentials, force bool) error
+	Status() ([]*model.FileChange, error)
+	AddAll() error
+	Add(paths []string) error
+	Unstage(paths []string) error
+	StageHunks(path string, accept func(*model.Hunk) (bool, error)) error
+	StagedAdditions() ([]*model.FileDiff, error)
+	LFSTracked(paths []string) (map[string]bool, error)
+	SigningConfig() (*model.Signing, bool, error)
+	SetSigner(sig *model.Signing, passphrase string) error
+	Identities(local bool) (author, committer *model.Identity, err error)
+	Commit(commitMsg string, author, committer *model.Identity, amend bool) error
+	ResolveCommit(rev string) (*model.Commit, error)
+	Tags() ([]*model.Tag, error)
+	CommitsBetween(from, to string) ([]*model.Commit, error)
+	CreateTag(name, rev, message string, tagger *model.Identity) error
+	DeleteTag(name string) error
+	IsPushed(remoteName string, branch model.Branch, rev string) (bool, error)
+	Autosquash(target string, committer *model.Identity) error
+	ExportIndex(dir string) error
+	Fetch(ctx context.Context, remote *model.Remote, branch model.Branch, auth *config.Credentials, progress io.Writer) error
+	Stash(committer *model.Identity) (string, error)
+	Unstash(stash string) ([]*model.Conflict, error)
+	RebaseState() (*model.Rebase, error)
+	Rebase(upstream *model.Upstream, autostash string, committer *model.Identity) (*model.Rebase, error)
+	RebaseContinue(committer *model.Identity) (*model.Rebase, error)
+	RebaseAbort() (*model.Rebase, error)
+	Merge(upstream *model.Upstream, committer *model.Identity) (*model.Merge, error)
+	Conflicts() ([]*model.Conflict, error)
+	ConflictView(path string) (string, error)
+	ResolveConflict(path string, theirs bool) error
+	HasConflictMarkers(path string) (bool, error)
+	MergeContinue(committer *model.Identity) (*model.Merge, error)
+	MergeAbort() error
+	Checkpoint() (*model.Checkpoint, error)
+	SaveCheckpoint(cp *model.Checkpoint) error
+	RemoveCheckpoint() error
+	RestoreCheckpoint(cp *model.Checkpoint) error
+	SaveIndex() error
+	RestoreIndex() error
+	RollbackRun(cp *model.Checkpoint) error
+	Pull(ctx context.Context, remote *model.Remote, branch model.Branch, auth *config.Credentials, force bool, progress io.Writer) error
+	Push(ctx context.Context, remote *model.Remote, branch, remoteBranch model.Branch, tags []string, auth *config.Credentials, forceWithLease bool, progress io.Writer) error
+	CheckPush(ctx context.Context, remote *model.Remote, branch, remoteBranch model.Branch, auth *config.Credentials, forceWithLease bool) error
 }
 
 type scriptHelper interface {
-	GetCurrentBranch() (model.Branch, error)
-	GenerateMocks() (string, error)
-	TestsPresent() (bool, error)
-	RunTests() (string, error)
+	GetCurrentBranch(ctx context.Context) (model.Branch, error)
+	GenerateMocks(ctx context.Context, dir string, packages []string, stream io.Writer) (string, error)
+	TestsPresent(ctx context.Context, dir string) (bool, error)
+	RunTests(ctx context.Context, dir string, stream io.Writer) (string, error)
 	Exists(path, name string) bool
 	CreateFile(path, name string) (*os.File, error)
 	CreateDir(path, name string) error
-	GenerateSSHKey(path, keyName, mail, passphrase string) error
+	GenerateSSHKey(ctx context.Context, path, keyName, mail, passphrase string) error
+	OpenEditor(ctx context.Context, path string) error
 
-	PullMerge() (string, error)
+	LFSTrack(ctx context.Context, path string) (string, error)
+	GitAdd(ctx context.Context, paths ...string) (string, error)
 }
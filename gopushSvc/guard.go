@@ -0,0 +1,136 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	defaultMaxFileSize = 50 << 20
+	gitignoreFile      = ".gitignore"
+	gitattributesFile  = ".gitattributes"
+)
+
+const (
+	guardLFS    = "track with git lfs"
+	guardIgnore = "add to .gitignore"
+	guardSkip   = "leave unstaged"
+	guardAbort  = "abort"
+)
+
+// guardReason explains why path may not be staged, empty when it is fine.
+func guardReason(p string, maxSize config.Size, patterns []string) string {
+	fi, err := os.Stat(p)
+	if err != nil || fi.IsDir() {
+		return ""
+	}
+	if config.Size(fi.Size()) > maxSize {
+		return fmt.Sprintf("is %s, over the %s limit", config.Size(fi.Size()), maxSize)
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, p); ok {
+			return fmt.Sprintf("matches binary pattern %q", pattern)
+		}
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return fmt.Sprintf("matches binary pattern %q", pattern)
+		}
+	}
+	return ""
+}
+
+func appendGitignore(p string) error {
+	content, err := os.ReadFile(gitignoreFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+	content = append(content, []byte(fmt.Sprintf("/%s\n", p))...)
+	return os.WriteFile(gitignoreFile, content, 0644)
+}
+
+func appendUnique(paths []string, p string) []string {
+	for _, existing := range paths {
+		if existing == p {
+			return paths
+		}
+	}
+	return append(paths, p)
+}
+
+// guardFiles checks paths against the size limit and binary patterns before
+// they are staged. It splits them into the paths go-git can stage and the ones
+// git lfs has to stage, offending files are tracked with lfs, ignored or left
+// out as the user decides. guarded is set when plain is not paths as given.
+func (s *Svc) guardFiles(ctx context.Context, paths []string) (plain, lfs []string, guarded bool, err error) {
+	maxSize := config.Size(defaultMaxFileSize)
+	patterns := []string{}
+	if s.cfg != nil {
+		if s.cfg.Guard.MaxFileSize > 0 {
+			maxSize = s.cfg.Guard.MaxFileSize
+		}
+		patterns = s.cfg.Guard.BinaryPatterns
+	}
+	lfsTracked, err := s.git.LFSTracked(paths)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	plain, lfs = []string{}, []string{}
+	blocked := false
+	for _, p := range paths {
+		if lfsTracked[p] {
+			lfs = append(lfs, p)
+			guarded = true
+			continue
+		}
+		reason := guardReason(p, maxSize, patterns)
+		if reason == "" {
+			plain = append(plain, p)
+			continue
+		}
+		guarded = true
+		if !utils.IsTerminal() {
+			utils.Logger(utils.LOG_WARNING, fmt.Sprintf("%s %s", p, reason))
+			blocked = true
+			continue
+		}
+
+		action, err := utils.Select(fmt.Sprintf("%s %s", p, reason), []string{guardLFS, guardIgnore, guardSkip, guardAbort})
+		if err != nil {
+			return nil, nil, false, err
+		}
+		switch action {
+		case guardLFS:
+			output, err := s.bash.LFSTrack(ctx, p)
+			if err != nil {
+				utils.Logger(utils.LOG_FAILURE, output)
+				return nil, nil, false, err
+			}
+			lfs = append(lfs, p)
+			lfs = appendUnique(lfs, gitattributesFile)
+		case guardIgnore:
+			err := appendGitignore(p)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			plain = appendUnique(plain, gitignoreFile)
+		case guardSkip:
+		default:
+			return nil, nil, false, ErrFileTooLarge
+		}
+	}
+	if blocked {
+		utils.Logger(utils.LOG_STRICT_INFO, "track them with git lfs, ignore them or raise [Guard] MaxFileSize")
+		return nil, nil, false, ErrFileTooLarge
+	}
+	return plain, lfs, guarded, nil
+}
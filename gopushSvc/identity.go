@@ -0,0 +1,130 @@
+package gopushSvc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const coAuthorToken = "Co-authored-by"
+
+// identities resolves the author and committer of a commit from the
+// repository git config, then the identity of the remote's credential
+// profile, then the global and system git config.
+func (s *Svc) identities() (author, committer *model.Identity, err error) {
+	localAuthor, localCommitter, err := s.git.Identities(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	globalAuthor, globalCommitter, err := s.git.Identities(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	profile := s.profileIdentity()
+	author = firstIdentity(localAuthor, profile, globalAuthor)
+	committer = firstIdentity(localCommitter, profile, globalCommitter)
+	if author == nil || committer == nil {
+		return nil, nil, ErrIdentityNotFound
+	}
+	return author, committer, nil
+}
+
+// profileIdentity is the identity stored with the credentials of the loaded
+// remote's provider, nil when there is none.
+func (s *Svc) profileIdentity() *model.Identity {
+	if s.cfg == nil {
+		return nil
+	}
+	remoteDetails, err := s.git.GetRemoteDetails()
+	if err != nil {
+		return nil
+	}
+	cred := s.cfg.ProviderAuth(s.cfg.RemoteProvider(remoteDetails))
+	if cred == nil {
+		return nil
+	}
+	return &model.Identity{Name: cred.Name, Email: cred.Email}
+}
+
+func firstIdentity(identities ...*model.Identity) *model.Identity {
+	for _, identity := range identities {
+		if identity.Valid() {
+			return identity
+		}
+	}
+	return nil
+}
+
+// coAuthors combines the saved pairing session with extra, dropping
+// duplicates and the author.
+func (s *Svc) coAuthors(author *model.Identity, extra []string) ([]*model.Identity, error) {
+	saved := []string{}
+	if s.cfg != nil {
+		saved = s.cfg.Commit.CoAuthors
+	}
+	seen := map[string]bool{strings.ToLower(author.Email): true}
+	coAuthors := []*model.Identity{}
+	for _, text := range append(saved, extra...) {
+		coAuthor, ok := model.ParseIdentity(text)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidIdentity, text)
+		}
+		email := strings.ToLower(coAuthor.Email)
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		coAuthors = append(coAuthors, coAuthor)
+	}
+	return coAuthors, nil
+}
+
+// Pair saves co-authors to add to every commit until the session is cleared.
+// The current session is printed.
+func (s *Svc) Pair(coAuthors []string, clear bool) error {
+	gopushDirPath, err := s.createConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Read(configFile, gopushDirPath)
+	if err != nil {
+		return err
+	}
+	if clear {
+		cfg.Commit.CoAuthors = nil
+	}
+	for _, text := range coAuthors {
+		coAuthor, ok := model.ParseIdentity(text)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrInvalidIdentity, text)
+		}
+		saved := false
+		for _, existing := range cfg.Commit.CoAuthors {
+			if e, ok := model.ParseIdentity(existing); ok && strings.EqualFold(e.Email, coAuthor.Email) {
+				saved = true
+			}
+		}
+		if !saved {
+			cfg.Commit.CoAuthors = append(cfg.Commit.CoAuthors, coAuthor.String())
+		}
+	}
+	if clear || len(coAuthors) > 0 {
+		err = cfg.Write(configFile, gopushDirPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.Commit.CoAuthors) == 0 {
+		utils.Logger(utils.LOG_SUCCESS, "not pairing")
+		return nil
+	}
+	utils.Logger(utils.LOG_INFO, "Pairing with...")
+	for _, coAuthor := range cfg.Commit.CoAuthors {
+		utils.Logger(utils.LOG_STRICT_INFO, coAuthor)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package gopushSvc
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	defaultHeaderMaxLength   = 100
+	defaultBodyMaxLineLength = 100
+	scissorsLine             = "# ------------------------ >8 ------------------------"
+)
+
+// ignoredHeaders are the headers git and the providers write on their own,
+// which are not linted, as commitlint ignores them by default.
+var ignoredHeaders = regexp.MustCompile(`^(?:Merge (?:pull request #\d+|branch |remote-tracking branch |tag )|Merged .* into |Revert ".*"|(?:fixup|squash|amend)! )`)
+
+type lintProblem struct {
+	rule    string
+	message string
+}
+
+func (p lintProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.rule, p.message)
+}
+
+// cleanCommitMsg drops the comment lines and everything below the scissors
+// line, the same way git does before recording a message.
+func cleanCommitMsg(text string) string {
+	if i := strings.Index(text, scissorsLine); i >= 0 {
+		text = text[:i]
+	}
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func (s *Svc) lintConfig() config.Lint {
+	lc := config.Lint{}
+	if s.cfg != nil {
+		lc = s.cfg.Lint
+	}
+	if lc.HeaderMaxLength <= 0 {
+		lc.HeaderMaxLength = defaultHeaderMaxLength
+	}
+	if lc.BodyMaxLineLength <= 0 {
+		lc.BodyMaxLineLength = defaultBodyMaxLineLength
+	}
+	if lc.SubjectCase == "" {
+		lc.SubjectCase = "lower"
+	}
+	return lc
+}
+
+// lintCommitMsg checks text against the Conventional Commits format and the
+// configured rules, named after their commitlint counterparts.
+func (s *Svc) lintCommitMsg(text string) []lintProblem {
+	cc, lc := s.commitConfig(), s.lintConfig()
+	problems := []lintProblem{}
+	report := func(rule, format string, args ...interface{}) {
+		if !slices.Contains(lc.Disabled, rule) {
+			problems = append(problems, lintProblem{rule, fmt.Sprintf(format, args...)})
+		}
+	}
+
+	text = cleanCommitMsg(text)
+	if text == "" {
+		report("header-empty", "commit message is empty")
+		return problems
+	}
+	lines := strings.Split(text, "\n")
+	header := lines[0]
+	if ignoredHeaders.MatchString(header) {
+		return problems
+	}
+
+	if utf8.RuneCountInString(header) > lc.HeaderMaxLength {
+		report("header-max-length", "header is longer than %d characters", lc.HeaderMaxLength)
+	}
+	msg, ok := model.ParseCommitMessage(text)
+	if !ok {
+		report("header-format", "header must look like \"type(scope): subject\"")
+	} else {
+		types := make([]string, len(cc.Types))
+		for i, t := range cc.Types {
+			types[i] = t.Name
+		}
+		if !slices.Contains(types, msg.Type) {
+			report("type-enum", "type %q is not one of %s", msg.Type, strings.Join(types, ", "))
+		}
+		if msg.Scope == "" && cc.RequireScope {
+			report("scope-empty", "scope is required")
+		}
+		if msg.Scope != "" && len(cc.Scopes) > 0 && !slices.Contains(cc.Scopes, msg.Scope) && !s.isTicketScope(msg.Scope) {
+			report("scope-enum", "scope %q is not one of %s", msg.Scope, strings.Join(cc.Scopes, ", "))
+		}
+		if strings.TrimSpace(msg.Subject) == "" {
+			report("subject-empty", "subject is required")
+		} else {
+			first, _ := utf8.DecodeRuneInString(s.trimTicketPrefix(msg.Subject))
+			if lc.SubjectCase == "lower" && unicode.IsUpper(first) {
+				report("subject-case", "subject must start with a lower case letter")
+			}
+			if lc.SubjectCase == "sentence" && unicode.IsLower(first) {
+				report("subject-case", "subject must start with an upper case letter")
+			}
+			if strings.HasSuffix(msg.Subject, ".") {
+				report("subject-full-stop", "subject must not end with a full stop")
+			}
+		}
+		if msg.Body == "" && cc.RequireBody {
+			report("body-empty", "body is required")
+		}
+	}
+
+	if len(lines) > 1 && lines[1] != "" {
+		report("body-leading-blank", "body must be separated from the header by a blank line")
+	}
+	for i, line := range lines[1:] {
+		if utf8.RuneCountInString(line) > lc.BodyMaxLineLength && !model.IsTrailer(line) {
+			report("body-max-line-length", "line %d is longer than %d characters", i+2, lc.BodyMaxLineLength)
+		}
+	}
+	s.lintFooter(lines[1:], report)
+	return problems
+}
+
+// lintFooter checks the last paragraph when it starts like a footer.
+func (s *Svc) lintFooter(lines []string, report func(rule, format string, args ...interface{})) {
+	start := len(lines)
+	for start > 0 && lines[start-1] != "" {
+		start--
+	}
+	if start == len(lines) || !model.IsTrailer(lines[start]) {
+		return
+	}
+	if start == 0 {
+		report("footer-leading-blank", "footer must be separated by a blank line")
+	}
+	for _, line := range lines[start:] {
+		token, _, _ := strings.Cut(line, ":")
+		switch {
+		case strings.EqualFold(token, "breaking change") && token != "BREAKING CHANGE":
+			report("footer-format", "breaking change footer must be spelled \"BREAKING CHANGE\"")
+		case model.IsTrailer(line), strings.HasPrefix(line, " "), strings.HasPrefix(line, "\t"):
+			// footer or the continuation of its value
+		default:
+			report("footer-format", "footer line %q must look like \"Token: value\" or \"Token #value\"", line)
+		}
+	}
+}
+
+// LintCommitMsg reports every rule text breaks, it returns ErrCommitLint when
+// any does.
+func (s *Svc) LintCommitMsg(text string) error {
+	problems := s.lintCommitMsg(text)
+	if len(problems) == 0 {
+		utils.Logger(utils.LOG_SUCCESS, "commit message ok")
+		return nil
+	}
+	for _, p := range problems {
+		utils.Logger(utils.LOG_WARNING, p.String())
+	}
+	return ErrCommitLint
+}
@@ -0,0 +1,29 @@
+package gopushSvc
+
+import "testing"
+
+func TestLintIgnoresGitHeaders(t *testing.T) {
+	s := &Svc{}
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"Merge branch 'main' into feature", true},
+		{"Merge remote-tracking branch 'origin/main' into main", true},
+		{"Merge pull request #12 from user/branch", true},
+		{"Merge tag 'v1.2.0'", true},
+		{"Revert \"feat: add thing\"\n\nThis reverts commit 1234567.", true},
+		{"fixup! feat: add thing", true},
+		{"squash! feat: add thing", true},
+		{"feat: add thing", true},
+		{"Merged stuff", false},
+		{"Add thing", false},
+		{"feat: Add thing.", false},
+	}
+	for _, tt := range tests {
+		problems := s.lintCommitMsg(tt.msg)
+		if got := len(problems) == 0; got != tt.want {
+			t.Errorf("lintCommitMsg(%q) = %v, want ok %v", tt.msg, problems, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package gopushSvc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// merge merges the fetched upstream into the current branch, fast-forwarding
+// when the branch has no commits of its own.
+func (s *Svc) merge(upstream *model.Upstream) error {
+	_, committer, err := s.identities()
+	if err != nil {
+		return err
+	}
+	err = s.loadSigner()
+	if err != nil {
+		return err
+	}
+	head, err := s.git.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+	result, err := s.git.Merge(upstream, committer)
+	if errors.Is(err, ErrAlreadyUpToDate) {
+		utils.Logger(utils.LOG_SUCCESS, "already up-to-date")
+		return nil
+	}
+	if errors.Is(err, ErrMergeConflict) {
+		utils.Logger(utils.LOG_FAILURE, fmt.Sprintf("conflicts while merging %s", upstream))
+		for _, conflict := range result.Conflicts {
+			utils.Logger(utils.LOG_STRICT_INFO, conflict.String())
+		}
+		return errors.Join(err, s.saveCheckpoint(head.Hash))
+	}
+	if err != nil {
+		return err
+	}
+	if result.FastForward {
+		utils.Logger(utils.LOG_SUCCESS, "changes pulled")
+		return nil
+	}
+	utils.Logger(utils.LOG_SUCCESS, "changes merged")
+	return nil
+}
+
+// finishMerge commits the merge once its conflicts are resolved.
+func (s *Svc) finishMerge() error {
+	_, committer, err := s.identities()
+	if err != nil {
+		return err
+	}
+	err = s.loadSigner()
+	if err != nil {
+		return err
+	}
+	_, err = s.git.MergeContinue(committer)
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, "changes merged")
+	return s.git.RemoveCheckpoint()
+}
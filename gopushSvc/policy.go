@@ -0,0 +1,149 @@
+package gopushSvc
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// matchBranch reports whether branch is one of patterns, path.Match patterns
+// like "release/*" included.
+func matchBranch(patterns []string, branch model.Branch) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch.String()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// branchPolicy adds up the rules of the policies matching branch.
+func (s *Svc) branchPolicy(branch model.Branch) config.Policy {
+	policy := config.Policy{}
+	if s.cfg == nil {
+		return policy
+	}
+	for _, p := range s.cfg.Policies {
+		if !matchBranch(p.Branches, branch) {
+			continue
+		}
+		policy.NoPush = policy.NoPush || p.NoPush
+		policy.NoForce = policy.NoForce || p.NoForce
+		policy.Linear = policy.Linear || p.Linear
+		policy.RequireTests = policy.RequireTests || p.RequireTests
+	}
+	return policy
+}
+
+// protected reports whether branch must never be force pushed, being in the
+// Push.Protected list or under a NoForce policy.
+func (s *Svc) protected(branch model.Branch) bool {
+	if s.cfg == nil {
+		return false
+	}
+	return matchBranch(s.cfg.Push.Protected, branch) || s.branchPolicy(branch).NoForce
+}
+
+// CheckPolicy applies the policy of the current branch before anything is
+// committed. On a branch that takes no direct pushes it offers to move the
+// work to a new feature branch, named with the branch template.
+func (s *Svc) CheckPolicy(ctx context.Context, skipTests bool) error {
+	branch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	policy := s.branchPolicy(branch)
+	if policy.RequireTests && skipTests {
+		return fmt.Errorf("%w: %s", ErrTestsRequired, branch)
+	}
+	if !policy.NoPush {
+		return nil
+	}
+
+	utils.Logger(utils.LOG_WARNING, fmt.Sprintf("%s does not take direct pushes", branch))
+	if !utils.IsTerminal() {
+		return fmt.Errorf("%w: %s", ErrPushNotAllowed, branch)
+	}
+	create, err := utils.Confirm("Create a feature branch instead")
+	if err != nil {
+		return err
+	}
+	if create {
+		err = s.BuildBranch()
+		if err != nil {
+			return err
+		}
+	}
+	current, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	if current == branch {
+		return fmt.Errorf("%w: %s", ErrPushNotAllowed, branch)
+	}
+	// the feature branch may have rules of its own
+	return s.CheckPolicy(ctx, skipTests)
+}
+
+// checkChangelogPolicy applies the policy of branch to the changelog commit
+// gopush release pushes straight to it, as gopush run does for its own: the
+// tests have to pass when they are required, and no merge may be pushed along
+// when the history must be linear. The commit only touches the changelog, so
+// the tests run before it is made.
+func (s *Svc) checkChangelogPolicy(ctx context.Context, branch model.Branch) error {
+	policy := s.branchPolicy(branch)
+	if policy.Linear {
+		targets, err := s.pushTargets()
+		if err != nil {
+			return err
+		}
+		t := targets[0]
+		t.branch, err = s.remoteBranch(branch, t.remote.Name, false)
+		if err != nil {
+			return err
+		}
+		err = s.checkLinear(ctx, branch, t)
+		if err != nil {
+			return err
+		}
+	}
+	if !policy.RequireTests {
+		return nil
+	}
+	utils.Logger(utils.LOG_INFO, "Running tests...")
+	_, err := s.CheckTestsAndRun(ctx, false, false)
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, "tests passed")
+	return nil
+}
+
+// checkLinear refuses to push merge commits from a branch that requires a
+// linear history. Merges already on the remote branch t pushes to, or on the
+// base branch, were accepted before and are not counted.
+func (s *Svc) checkLinear(ctx context.Context, branch model.Branch, t *pushTarget) error {
+	if !s.branchPolicy(branch).Linear {
+		return nil
+	}
+	base, err := s.baseBranch(ctx, t.remote, t.auth)
+	if err != nil {
+		return err
+	}
+	branches := []model.Branch{t.branch}
+	if base != "" {
+		branches = append(branches, base)
+	}
+	merges, err := s.git.UnpushedMerges(t.remote.Name, branches)
+	if err != nil {
+		return err
+	}
+	if len(merges) > 0 {
+		return fmt.Errorf("%w %s", ErrNonLinear, merges[0].ShortHash())
+	}
+	return nil
+}
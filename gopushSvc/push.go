@@ -0,0 +1,153 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// pushTarget is a remote the branch is pushed to, with the credentials for
+// it and the outcome of the last attempt.
+type pushTarget struct {
+	remote   *model.Remote
+	provider model.Provider
+	auth     *config.Credentials
+	// branch is the remote branch that is updated.
+	branch model.Branch
+	err    error
+}
+
+// remoteAuth picks the credentials for remote: those of provider from the
+// config over http, the passphrase of the gopush key over ssh.
+func (s *Svc) remoteAuth(remote *model.Remote, provider model.Provider) (*config.Credentials, error) {
+	switch remote.AuthMode() {
+	case model.AuthHTTP:
+		if s.cfg == nil {
+			return nil, ErrConfigNotLoaded
+		}
+		auth := s.cfg.ProviderAuth(provider)
+		if auth == nil {
+			return nil, ErrAuthNotFound
+		}
+		return auth, nil
+	case model.AuthSSH:
+		if !s.passphrase.Valid() {
+			passphrase, err := utils.Prompt(true, false, "passphrase")
+			if err != nil {
+				return nil, err
+			}
+			s.passphrase = model.Password(passphrase)
+		}
+		return &config.Credentials{
+			Token: s.passphrase.String(),
+		}, nil
+	}
+	return nil, ErrInvalidAuthMethod
+}
+
+// pushTargets is the loaded remote followed by the configured mirrors. Mirrors
+// the repository has no remote for are skipped.
+func (s *Svc) pushTargets() ([]*pushTarget, error) {
+	remoteDetails, err := s.git.GetRemoteDetails()
+	if err != nil {
+		return nil, err
+	}
+	targets := []*pushTarget{{remote: remoteDetails, provider: s.cfg.RemoteProvider(remoteDetails)}}
+	if s.cfg != nil {
+		for _, mirror := range s.cfg.Push.Mirrors {
+			if mirror.Remote == remoteDetails.Name {
+				continue
+			}
+			remote, err := s.git.Remote(mirror.Remote)
+			if errors.Is(err, ErrRemoteNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			provider := model.ParseProvider(mirror.Provider)
+			if provider == model.ProviderUNKOWN {
+				provider = s.cfg.RemoteProvider(remote)
+			}
+			targets = append(targets, &pushTarget{remote: remote, provider: provider})
+		}
+	}
+	for _, target := range targets {
+		target.auth, err = s.remoteAuth(target.remote, target.provider)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", target.remote.Name, err)
+		}
+	}
+	return targets, nil
+}
+
+// eachTarget runs fn for every target concurrently, keeping the error in the
+// target. Targets the passphrase was wrong for are retried once it is asked
+// again. Each round gets its own push stage timeout, so the time spent typing
+// the passphrase does not count against it.
+func (s *Svc) eachTarget(ctx context.Context, targets []*pushTarget, fn func(context.Context, *pushTarget) error) error {
+	pending := targets
+	for len(pending) > 0 {
+		stageCtx, cancel := s.stageContext(ctx, model.StagePush)
+		var wg sync.WaitGroup
+		for _, target := range pending {
+			wg.Add(1)
+			go func(target *pushTarget) {
+				defer wg.Done()
+				target.err = fn(stageCtx, target)
+			}(target)
+		}
+		wg.Wait()
+		if stageCtx.Err() != nil {
+			err := stageError(stageCtx, model.StagePush, stageCtx.Err())
+			cancel()
+			return err
+		}
+		cancel()
+
+		retry := []*pushTarget{}
+		for _, target := range pending {
+			if errors.Is(target.err, ErrInvalidPassphrase) {
+				retry = append(retry, target)
+			}
+		}
+		if len(retry) == 0 {
+			return nil
+		}
+		passphrase, err := utils.Prompt(true, false, "invalid passphrase")
+		if err != nil {
+			return err
+		}
+		s.passphrase = model.Password(passphrase)
+		for _, target := range retry {
+			target.auth = &config.Credentials{
+				Token: s.passphrase.String(),
+			}
+		}
+		pending = retry
+	}
+	return nil
+}
+
+// failedTargets logs the outcome of every mirror push and returns the names
+// of the remotes that failed.
+func failedTargets(targets []*pushTarget) []string {
+	failed := []string{}
+	for _, target := range targets {
+		switch {
+		case target.err == nil:
+			utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("pushed to %s", target.remote.Name))
+		case errors.Is(target.err, ErrAlreadyUpToDate):
+			utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("%s already up-to-date", target.remote.Name))
+		default:
+			utils.Logger(utils.LOG_FAILURE, fmt.Sprintf("%s: %v", target.remote.Name, target.err))
+			failed = append(failed, target.remote.Name)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,125 @@
+package gopushSvc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// rebase replays the local commits onto the fetched upstream. Uncommitted
+// changes to tracked files are stashed first and restored once the rebase is
+// over, or kept with the stopped rebase until it is continued or aborted.
+func (s *Svc) rebase(upstream *model.Upstream) error {
+	_, committer, err := s.identities()
+	if err != nil {
+		return err
+	}
+	err = s.loadSigner()
+	if err != nil {
+		return err
+	}
+	head, err := s.git.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+	stash, err := s.git.Stash(committer)
+	if err != nil {
+		return err
+	}
+	if stash != "" {
+		utils.Logger(utils.LOG_STRICT_INFO, "local changes stashed")
+	}
+
+	state, err := s.git.Rebase(upstream, stash, committer)
+	if errors.Is(err, ErrRebaseConflict) {
+		reportConflicts(state)
+		return errors.Join(err, s.saveCheckpoint(head.Hash))
+	}
+	if errors.Is(err, ErrAlreadyUpToDate) {
+		utils.Logger(utils.LOG_SUCCESS, "already up-to-date")
+		return s.unstash(stash)
+	}
+	if err != nil {
+		if stash != "" {
+			utils.Logger(utils.LOG_WARNING, fmt.Sprintf("local changes are kept in stash %s", stash[:7]))
+		}
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("%d commits rebased onto %s", state.Replayed, upstream))
+	return s.unstash(stash)
+}
+
+// unstash restores the autostash, a no-op for an empty one.
+func (s *Svc) unstash(stash string) error {
+	if stash == "" {
+		return nil
+	}
+	conflicts, err := s.git.Unstash(stash)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		utils.Logger(utils.LOG_SUCCESS, "local changes restored")
+		return nil
+	}
+	utils.Logger(utils.LOG_WARNING, fmt.Sprintf("local changes restored with conflicts, the stash is kept as %s", stash[:7]))
+	for _, conflict := range conflicts {
+		utils.Logger(utils.LOG_STRICT_INFO, conflict.String())
+	}
+	return nil
+}
+
+func reportConflicts(state *model.Rebase) {
+	utils.Logger(utils.LOG_FAILURE, fmt.Sprintf("conflicts while replaying %s", state.Todo[0][:7]))
+	for _, conflict := range state.Conflicts {
+		utils.Logger(utils.LOG_STRICT_INFO, conflict.String())
+	}
+}
+
+// RebaseContinue commits the resolved files of a stopped rebase and replays
+// the remaining commits, restoring the autostash when done.
+func (s *Svc) RebaseContinue() error {
+	err := s.continueRebase()
+	if errors.Is(err, ErrRebaseConflict) {
+		conflictHelp(true)
+	}
+	return err
+}
+
+func (s *Svc) continueRebase() error {
+	_, committer, err := s.identities()
+	if err != nil {
+		return err
+	}
+	err = s.loadSigner()
+	if err != nil {
+		return err
+	}
+	state, err := s.git.RebaseContinue(committer)
+	if errors.Is(err, ErrRebaseConflict) {
+		reportConflicts(state)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("%d commits rebased", state.Replayed))
+	err = s.unstash(state.Autostash)
+	if err != nil {
+		return err
+	}
+	return s.git.RemoveCheckpoint()
+}
+
+// RebaseAbort puts the branch back where it was before the rebase and
+// restores the autostash.
+func (s *Svc) RebaseAbort() error {
+	state, err := s.git.RebaseAbort()
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, "rebase aborted")
+	return s.unstash(state.Autostash)
+}
@@ -0,0 +1,203 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// release is a release tag and the version it names.
+type release struct {
+	tag     *model.Tag
+	version model.Version
+}
+
+// releases lists the release tags reachable from HEAD, oldest version first.
+// Of several tags naming the same version the first one found is kept.
+func (s *Svc) releases() ([]release, error) {
+	tags, err := s.git.Tags()
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.git.CommitsBetween("", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	reachable := map[string]bool{}
+	for _, c := range history {
+		reachable[c.Hash] = true
+	}
+	releases := []release{}
+	seen := map[model.Version]bool{}
+	for _, tag := range tags {
+		v, ok := model.ParseVersion(tag.Name)
+		if !ok || !reachable[tag.Commit] || seen[v] {
+			continue
+		}
+		seen[v] = true
+		releases = append(releases, release{tag: tag, version: v})
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].version.Less(releases[j].version)
+	})
+	return releases, nil
+}
+
+// lastRelease finds the highest release tag reachable from HEAD, nil when
+// nothing was released yet.
+func (s *Svc) lastRelease() (*model.Tag, model.Version, error) {
+	releases, err := s.releases()
+	if err != nil || len(releases) == 0 {
+		return nil, model.Version{}, err
+	}
+	last := releases[len(releases)-1]
+	return last.tag, last.version, nil
+}
+
+// releaseBump is the largest bump the conventional commits call for.
+func releaseBump(commits []*model.Commit) model.Bump {
+	bump := model.BumpNone
+	for _, c := range commits {
+		msg, ok := model.ParseCommitMessage(c.Message)
+		if ok && model.BumpFor(msg) > bump {
+			bump = model.BumpFor(msg)
+		}
+	}
+	return bump
+}
+
+// Release tags HEAD with the version following the last release tag, bumped
+// as the conventional commits since then call for, and pushes the tag along
+// with the branch. bump ("major", "minor" or "patch") overrides the computed
+// one. With changelog the changelog is updated and committed first, so that
+// the tag includes it, under the same branch policy as gopush run. With
+// dryRun the release is only shown. Branches that take no direct pushes are
+// not released from. When the push fails the tag is deleted again and the
+// changelog commit undone, its changes left in the working tree, so that the
+// release can be retried.
+func (s *Svc) Release(ctx context.Context, bump string, dryRun, changelog bool) error {
+	branch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	if s.branchPolicy(branch).NoPush {
+		return fmt.Errorf("%w: %s", ErrPushNotAllowed, branch)
+	}
+	forced := model.BumpNone
+	if bump != "" {
+		forced = model.ParseBump(bump)
+		if forced == model.BumpNone {
+			return fmt.Errorf("%w, got %q", ErrInvalidBump, bump)
+		}
+	}
+	last, version, err := s.lastRelease()
+	if err != nil {
+		return err
+	}
+	from, previous := "", "nothing"
+	if last != nil {
+		from, previous = last.Commit, last.Name
+	}
+	commits, err := s.git.CommitsBetween(from, "HEAD")
+	if err != nil {
+		return err
+	}
+	next := forced
+	if next == model.BumpNone {
+		next = releaseBump(commits)
+	}
+	if len(commits) == 0 || next == model.BumpNone {
+		if last != nil {
+			return fmt.Errorf("%w since %s", ErrNothingToRelease, last.Name)
+		}
+		return ErrNothingToRelease
+	}
+	tag := version.Next(next).Tag()
+	utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s after %s, a %s release of %d commits", tag, previous, next, len(commits)))
+	if dryRun {
+		for _, c := range commits {
+			utils.Logger(utils.LOG_INFO, fmt.Sprintf("  %s %s", c.ShortHash(), c.Subject()))
+		}
+		if changelog {
+			utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s would be committed first", s.changelogConfig().File))
+		}
+		return nil
+	}
+	if utils.IsTerminal() {
+		ok, err := utils.Confirm("Release %s", tag)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	author, committer, err := s.identities()
+	if err != nil {
+		return err
+	}
+	err = s.loadSigner()
+	if err != nil {
+		return err
+	}
+	head, err := s.git.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+	if changelog {
+		err = s.checkChangelogPolicy(ctx, branch)
+		if err != nil {
+			return err
+		}
+		err = s.commitChangelog(tag, author, committer)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.git.CreateTag(tag, "HEAD", releaseMessage(tag, commits), committer)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, tag)
+	}
+	utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("tagged %s", tag))
+
+	utils.Logger(utils.LOG_INFO, "Pushing release...")
+	err = s.push(ctx, []string{tag}, false, false)
+	if err != nil {
+		// a local tag left behind would make the next attempt fail with TagExists
+		delErr := s.git.DeleteTag(tag)
+		if delErr == nil {
+			utils.Logger(utils.LOG_WARNING, fmt.Sprintf("%s deleted, it was not pushed", tag))
+		}
+		return errors.Join(err, delErr, s.undoChangelogCommit(branch, head))
+	}
+	return nil
+}
+
+// undoChangelogCommit moves branch back to head when the release committed
+// the changelog on top of it, leaving the changelog changes uncommitted.
+func (s *Svc) undoChangelogCommit(branch model.Branch, head *model.Commit) error {
+	current, err := s.git.ResolveCommit("HEAD")
+	if err != nil || current.Hash == head.Hash {
+		return err
+	}
+	err = s.git.RestoreCheckpoint(&model.Checkpoint{Branch: branch, Head: head.Hash})
+	if err != nil {
+		utils.Logger(utils.LOG_WARNING, fmt.Sprintf("the changelog commit %s is still on %s", current.ShortHash(), branch))
+		return err
+	}
+	utils.Logger(utils.LOG_WARNING, fmt.Sprintf("changelog commit undone, %s is left modified", s.changelogConfig().File))
+	return nil
+}
+
+// releaseMessage lists the subjects of the released commits under the tag.
+func releaseMessage(tag string, commits []*model.Commit) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Release %s\n\n", tag)
+	for _, c := range commits {
+		fmt.Fprintf(&b, "- %s\n", c.Subject())
+	}
+	return b.String()
+}
@@ -1,6 +1,7 @@
 package gopushSvc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -66,71 +67,187 @@ func (s *Svc) InitializeRemote() error {
 	return nil
 }
 
-func (s *Svc) Pull(force bool) error {
-	pullBranch, err := s.bash.GetCurrentBranch()
+// Pull fetches the remote changes of the current branch and merges them in,
+// then does the same with the base branch so that the tests run against the
+// integrated code. With rebase, or the pull.rebase config default, local
+// commits are replayed onto the fetched branches instead, as they always are
+// on branches whose policy requires a linear history. Commits already on the
+// remote branch are not rebased onto the base, which would need a forced
+// push: the base is merged instead, or the pull fails when the history must
+// be linear. With forceWithLease the push replaces the remote branch, so only
+// the base branch is brought in, and rebased freely.
+// force resets the branch to the remote one with a go-git pull.
+func (s *Svc) Pull(ctx context.Context, force, rebase, forceWithLease bool) error {
+	rebase = !force && (rebase || (s.cfg != nil && s.cfg.Pull.Rebase))
+	state, err := s.git.RebaseState()
 	if err != nil {
 		return err
 	}
+	if state != nil {
+		return ErrRebaseInProgress
+	}
+	if s.checkpoint != nil && s.checkpoint.Stopped == "" {
+		// what the pull brings in is dropped again if the run is undone
+		head, err := s.git.ResolveCommit("HEAD")
+		if err != nil {
+			return err
+		}
+		s.checkpoint.Stopped = head.Hash
+	}
+
+	pullBranch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	// merging would break the linear history the branch requires
+	linear := !force && s.branchPolicy(pullBranch).Linear
+	rebase = rebase || linear
 	remoteDetails, err := s.git.GetRemoteDetails()
 	if err != nil {
 		return err
 	}
+	// a tracked branch may have another name on the remote
+	upstream, err := s.git.Upstream(pullBranch)
+	if err != nil {
+		return err
+	}
+	if upstream != nil && upstream.Remote == remoteDetails.Name {
+		pullBranch = upstream.Branch
+	}
 
-	var providerAuth *config.Credentials
-	if remoteDetails.AuthMode() == model.AuthHTTP {
-		if s.cfg == nil {
-			return ErrConfigNotLoaded
-		}
-		providerAuth = s.cfg.ProviderAuth(remoteDetails.Provider())
-		if providerAuth == nil {
-			return ErrAuthNotFound
-		}
-	} else if remoteDetails.AuthMode() == model.AuthSSH {
-		if !s.passphrase.Valid() {
-			passphrase, err := utils.Prompt(true, false, "passphrase")
+	providerAuth, err := s.remoteAuth(remoteDetails, s.cfg.RemoteProvider(remoteDetails))
+	if err != nil {
+		return err
+	}
+	// the stage timeout starts once the credentials are known, and again
+	// after the passphrase is asked for another time
+	stageCtx, cancel := s.stageContext(ctx, model.StagePull)
+	defer func() { cancel() }()
+	progress := utils.NewProgress(model.StagePull.String())
+	defer progress.Close()
+	pull := func(branch model.Branch) error {
+		var err error
+		for {
+			if force {
+				err = s.git.Pull(stageCtx, remoteDetails, branch, providerAuth, force, progress)
+			} else {
+				err = s.git.Fetch(stageCtx, remoteDetails, branch, providerAuth, progress)
+			}
+			if !errors.Is(err, ErrInvalidPassphrase) {
+				break
+			}
+			passphrase, err := utils.Prompt(true, false, "invalid passphrase")
 			if err != nil {
 				return err
 			}
 			s.passphrase = model.Password(passphrase)
+			providerAuth = &config.Credentials{
+				Token: s.passphrase.String(),
+			}
+			cancel()
+			stageCtx, cancel = s.stageContext(ctx, model.StagePull)
 		}
-		providerAuth = &config.Credentials{
-			Token: s.passphrase.String(),
+		if stageCtx.Err() != nil {
+			return stageError(stageCtx, model.StagePull, err)
 		}
+		if errors.Is(err, ErrKeyNotSupported) {
+			message := fmt.Sprintf("copy contents of %s.pub and upload the keys on %s", filepath.Join(os.Getenv("HOME"), gopushDir, keyName), remoteDetails.Host())
+			utils.Logger(utils.LOG_STRICT_INFO, message)
+		}
+		return err
+	}
+	if force {
+		pullErr := pull(pullBranch)
+		progress.Close()
+		if errors.Is(pullErr, ErrAlreadyUpToDate) {
+			utils.Logger(utils.LOG_SUCCESS, "already up-to-date")
+			return nil
+		}
+		if pullErr == nil {
+			utils.Logger(utils.LOG_SUCCESS, "changes pulled")
+		}
+		return pullErr
+	}
+
+	branches := []model.Branch{}
+	pushed := false
+	if forceWithLease {
+		// the remote branch is replaced, the lease checks it as last fetched
+		utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s/%s is replaced, not pulled", remoteDetails.Name, pullBranch))
 	} else {
-		return ErrInvalidAuthMethod
+		pullErr := pull(pullBranch)
+		switch {
+		case errors.Is(pullErr, ErrRemoteBranchNotFound):
+			// a new branch, only the base has something to bring in
+			utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s is not on %s yet", pullBranch, remoteDetails.Name))
+		case pullErr == nil || errors.Is(pullErr, ErrAlreadyUpToDate):
+			branches = append(branches, pullBranch)
+			pushed = true
+		default:
+			return pullErr
+		}
 	}
-	pullErr := s.git.Pull(remoteDetails, pullBranch, providerAuth, force)
-	for errors.Is(pullErr, ErrInvalidPassphrase) {
-		passphrase, err := utils.Prompt(true, false, "invalid passphrase")
+	base, err := s.baseBranch(stageCtx, remoteDetails, providerAuth)
+	if err != nil {
+		return err
+	}
+	if base != "" && base != pullBranch {
+		pullErr := pull(base)
+		if pullErr != nil && !errors.Is(pullErr, ErrAlreadyUpToDate) {
+			return pullErr
+		}
+		branches = append(branches, base)
+	}
+	progress.Close()
+
+	baseRebase := rebase
+	if rebase && pushed && base != "" && base != pullBranch {
+		// replaying commits the remote branch already has onto the base
+		// rewrites them, and only a forced push would take them
+		tip := fmt.Sprintf("refs/remotes/%s/%s", remoteDetails.Name, pullBranch)
+		inBase, err := s.git.IsPushed(remoteDetails.Name, base, tip)
 		if err != nil {
 			return err
 		}
-		s.passphrase = model.Password(passphrase)
-		providerAuth = &config.Credentials{
-			Token: s.passphrase.String(),
+		if !inBase && linear {
+			return fmt.Errorf("%w: rebasing %s/%s onto %s", ErrRewritePushed, remoteDetails.Name, pullBranch, base)
+		}
+		if !inBase {
+			utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s/%s has commits %s lacks, merging it instead of rebasing", remoteDetails.Name, pullBranch, base))
+			baseRebase = false
 		}
-		pullErr = s.git.Pull(remoteDetails, pullBranch, providerAuth, force)
 	}
-	if errors.Is(pullErr, ErrKeyNotSupported) {
-		message := fmt.Sprintf("copy contents of %s.pub and upload the keys on %s", filepath.Join(os.Getenv("HOME"), gopushDir, keyName), remoteDetails.Provider().String())
-		utils.Logger(utils.LOG_STRICT_INFO, message)
-	} else if errors.Is(pullErr, ErrAlreadyUpToDate) {
-		utils.Logger(utils.LOG_SUCCESS, "already up-to-date")
-		return nil
-	} else if errors.Is(pullErr, ErrMergeFailed) {
-		output, err := s.bash.PullMerge()
+
+	// the tracking refs may have been fetched before, integrate them anyway
+	for _, branch := range branches {
+		upstream := &model.Upstream{Remote: remoteDetails.Name, Branch: branch}
+		if branch == base {
+			utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("base branch %s", upstream))
+		}
+		if rebase && (branch != base || baseRebase) {
+			err = s.rebase(upstream)
+		} else {
+			err = s.merge(upstream)
+		}
 		if err != nil {
-			utils.Logger(utils.LOG_FAILURE, output)
 			return err
 		}
-		utils.Logger(utils.LOG_SUCCESS, "changes merged")
-		return nil
 	}
+	return nil
+}
 
-	if pullErr == nil {
-		utils.Logger(utils.LOG_SUCCESS, "changes pulled")
+// baseBranch is the branch feature branches are brought up to date with:
+// Pull.Base from the config, or else the branch HEAD points to on remote.
+// It is empty when the remote has no branches yet.
+func (s *Svc) baseBranch(ctx context.Context, remote *model.Remote, auth *config.Credentials) (model.Branch, error) {
+	if s.cfg != nil && s.cfg.Pull.Base != "" {
+		return model.Branch(s.cfg.Pull.Base), nil
+	}
+	base, err := s.git.RemoteHead(ctx, remote, auth)
+	if errors.Is(err, ErrRemoteBranchNotFound) {
+		return "", nil
 	}
-	return pullErr
+	return base, err
 }
 
 func (s *Svc) SwitchBranchIfExists(branch model.Branch) (bool, error) {
@@ -147,121 +264,343 @@ func (s *Svc) SwitchBranchIfExists(branch model.Branch) (bool, error) {
 	return false, nil
 }
 
+// CreateBranchAndSwitch names branch with the branch template, then creates
+// and checks it out. An existing branch with the templated name is checked
+// out instead.
 func (s *Svc) CreateBranchAndSwitch(branch model.Branch) error {
-	err := s.git.CreateBranch(branch)
+	name, err := s.branchName(branch)
 	if err != nil {
 		return err
 	}
-	return s.git.CheckoutBranch(branch)
+	if name != branch {
+		utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("branch %s", name))
+	}
+	return s.createBranch(name)
 }
 
-func generateCommitMsg() (string, error) {
-	items := []string{"fix", "breaking fix", "feature", "breaking feature", "chore", "refactor", "ci"}
-	commitType, err := utils.Select(items)
+// StageChanges stages every change when all is set, otherwise it lets the user
+// pick the files to commit and, with patch, the hunks within them. Large and
+// binary files are guarded and the result is scanned for secrets, the index
+// going back to what it was when some are found.
+func (s *Svc) StageChanges(ctx context.Context, all, patch bool) error {
+	changes, err := s.git.Status()
 	if err != nil {
-		return "", err
+		return err
+	}
+	if len(changes) == 0 {
+		utils.Logger(utils.LOG_SUCCESS, "no files changed")
+		return nil
+	}
+	// staging adds files the user may not want staged once secrets are found
+	// or the run is undone
+	err = s.git.SaveIndex()
+	if err != nil {
+		return err
 	}
 
-	shortner := map[string]string{
-		"refactor":         "ref",
-		"feature":          "feat",
-		"breaking fix":     "fix!",
-		"breaking feature": "feat!",
+	picked, dropped := []string{}, []string{}
+	if all {
+		for _, change := range changes {
+			picked = append(picked, change.Path)
+		}
+	} else {
+		if !utils.IsTerminal() {
+			return ErrNoTerminal
+		}
+		items := make([]string, len(changes))
+		selected := make([]bool, len(changes))
+		for i, change := range changes {
+			items[i] = change.String()
+			selected[i] = change.Staged()
+		}
+		err = utils.MultiSelect("Select files to commit", items, selected)
+		if err != nil {
+			return err
+		}
+		for i, change := range changes {
+			if selected[i] {
+				picked = append(picked, change.Path)
+			} else if change.Staged() {
+				dropped = append(dropped, change.Path)
+			}
+		}
+	}
+	err = s.git.Unstage(dropped)
+	if err != nil {
+		return err
+	}
+	if len(picked) == 0 {
+		utils.Logger(utils.LOG_SUCCESS, "no files staged")
+		return nil
 	}
 
-	if _, ok := shortner[commitType]; ok {
-		commitType = shortner[commitType]
+	plain, lfs, guarded, err := s.guardFiles(ctx, picked)
+	if err != nil {
+		return err
+	}
+	switch {
+	case all && !guarded:
+		err = s.git.AddAll()
+	case patch:
+		for _, path := range plain {
+			err = s.git.StageHunks(path, acceptHunk)
+			if err != nil {
+				break
+			}
+		}
+	default:
+		err = s.git.Add(plain)
+	}
+	if err != nil {
+		return err
+	}
+	if len(lfs) > 0 {
+		output, err := s.bash.GitAdd(ctx, lfs...)
+		if err != nil {
+			utils.Logger(utils.LOG_FAILURE, output)
+			return err
+		}
 	}
 
-	msg, err := utils.Prompt(false, false, "commit message")
+	staged, err := s.stagedCount()
 	if err != nil {
-		return "", err
+		return err
 	}
+	utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("%d files staged", staged))
+	err = s.checkSecrets()
+	if errors.Is(err, ErrSecretsFound) {
+		utils.Logger(utils.LOG_WARNING, "changes unstaged, the index is back as it was before staging")
+		return errors.Join(err, s.git.RestoreIndex())
+	}
+	return err
+}
 
-	return fmt.Sprintf("%s: %s", commitType, msg), nil
+func acceptHunk(hunk *model.Hunk) (bool, error) {
+	if hunk.Whole {
+		return utils.Confirm("Stage %s", hunk.Path)
+	}
+	utils.Detail(hunk.Path, hunk.String())
+	return utils.Confirm("Stage this hunk")
 }
 
-func (s *Svc) StageChanges() error {
-	change, err := s.git.ChangeOccured()
+// stagedCount is the number of files the next commit records.
+func (s *Svc) stagedCount() (int, error) {
+	changes, err := s.git.Status()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, change := range changes {
+		if change.Staged() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Svc) stagedChanges() (bool, error) {
+	count, err := s.stagedCount()
+	return count > 0, err
+}
+
+// Commit records the staged changes with message, prompting for one when it
+// is empty. The branch ticket is added and the message must pass the commit
+// lint rules. The saved pairing session and coAuthors are credited with
+// Co-authored-by trailers, and the commit is signed when signing is enabled.
+//
+// With amend the changes replace HEAD, keeping its message unless one is
+// given. With fixup they become a fixup commit for that commit, squashed into
+// it right away.
+func (s *Svc) Commit(ctx context.Context, message string, coAuthors []string, amend bool, fixup string) error {
+	staged, err := s.stagedChanges()
+	if err != nil {
+		return err
+	}
+	// amending with a message alone rewords HEAD
+	if !staged && !(amend && message != "") {
+		return nil
+	}
+	author, committer, err := s.identities()
 	if err != nil {
 		return err
 	}
-	if change {
-		commitMsg, err := generateCommitMsg()
+
+	var target *model.Commit
+	switch {
+	case fixup != "":
+		target, err = s.git.ResolveCommit(fixup)
 		if err != nil {
 			return err
 		}
-		err = s.git.AddThenCommit(commitMsg)
+		message = fmt.Sprintf("fixup! %s", target.Subject())
+	case amend && message == "":
+		head, err := s.git.ResolveCommit("HEAD")
+		if err != nil {
+			return err
+		}
+		message = head.Message
+	default:
+		message, err = s.commitMessage(ctx, message, author, coAuthors)
 		if err != nil {
 			return err
 		}
-		utils.Logger(utils.LOG_SUCCESS, "files added")
-	} else {
-		utils.Logger(utils.LOG_SUCCESS, "no files changed")
 	}
-	return nil
-}
 
-func (s *Svc) Push(force bool) error {
-	currBranch, err := s.bash.GetCurrentBranch()
+	err = s.loadSigner()
 	if err != nil {
 		return err
 	}
-	remoteDetails, err := s.git.GetRemoteDetails()
+	err = s.git.Commit(strings.TrimRight(message, "\n")+"\n", author, committer, amend)
 	if err != nil {
 		return err
 	}
+	if target != nil {
+		return s.autosquash(target, committer)
+	}
+	if amend {
+		utils.Logger(utils.LOG_SUCCESS, "commit amended")
+		return nil
+	}
+	utils.Logger(utils.LOG_SUCCESS, "changes committed")
+	return nil
+}
 
-	var providerAuth *config.Credentials
-	if remoteDetails.AuthMode() == model.AuthHTTP {
-		if s.cfg == nil {
-			return ErrConfigNotLoaded
+// commitMessage completes message, or the one prompted for when it is empty,
+// with the branch ticket and co-author trailers once it passes the lint rules.
+func (s *Svc) commitMessage(ctx context.Context, message string, author *model.Identity, coAuthors []string) (string, error) {
+	pairs, err := s.coAuthors(author, coAuthors)
+	if err != nil {
+		return "", err
+	}
+	ticket, err := s.branchTicket(ctx)
+	if err != nil {
+		return "", err
+	}
+	if message != "" {
+		message = s.ticketMessage(message, ticket)
+		err = s.LintCommitMsg(message)
+		if err != nil {
+			return "", err
 		}
-		providerAuth = s.cfg.ProviderAuth(remoteDetails.Provider())
-		if providerAuth == nil {
-			return ErrAuthNotFound
+	}
+	for message == "" {
+		message, err = s.generateCommitMsg(ticket)
+		if err != nil {
+			return "", err
 		}
-	} else if remoteDetails.AuthMode() == model.AuthSSH {
-		if !s.passphrase.Valid() {
-			passphrase, err := utils.Prompt(true, false, "passphrase")
-			if err != nil {
-				return err
-			}
-			s.passphrase = model.Password(passphrase)
+		message, err = s.fixCommitMsg(ctx, message)
+		if err != nil {
+			return "", err
 		}
-		providerAuth = &config.Credentials{
-			Token: s.passphrase.String(),
+	}
+	message = cleanCommitMsg(message)
+	for _, coAuthor := range pairs {
+		if !strings.Contains(message, coAuthor.String()) {
+			message = appendTrailer(message, coAuthorToken, coAuthor.String())
 		}
-	} else {
-		return ErrInvalidAuthMethod
 	}
+	return message, nil
+}
+
+// Push pushes the current branch to the loaded remote and the configured
+// mirrors, concurrently. Unless the mirrors are best effort, every remote is
+// checked first and nothing is pushed when one of them would refuse, a remote
+// failing during the push itself is reported but does not undo the pushes
+// to the others. After the
+// first successful push the branch tracks its counterpart on the loaded
+// remote, setUpstream makes it track the loaded remote even when it tracks
+// another one. forceWithLease replaces the remote branch as long as it did not
+// move since the last fetch, protected branches are never forced.
+func (s *Svc) Push(ctx context.Context, setUpstream, forceWithLease bool) error {
+	return s.push(ctx, nil, setUpstream, forceWithLease)
+}
 
-	if providerAuth == nil {
-		return ErrAuthLoadFailed
+// push pushes the current branch with tags, see Push.
+func (s *Svc) push(ctx context.Context, tags []string, setUpstream, forceWithLease bool) error {
+	currBranch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	if forceWithLease && s.protected(currBranch) {
+		return fmt.Errorf("%w: %s", ErrProtectedBranch, currBranch)
+	}
+	targets, err := s.pushTargets()
+	if err != nil {
+		return err
+	}
+	remoteDetails := targets[0].remote
+	for _, t := range targets {
+		t.branch, err = s.remoteBranch(currBranch, t.remote.Name, setUpstream)
+		if err != nil {
+			return err
+		}
 	}
-	pushErr := s.git.Push(remoteDetails, currBranch, providerAuth, force)
-	for errors.Is(pushErr, ErrInvalidPassphrase) {
-		passphrase, err := utils.Prompt(true, false, "invalid passphrase")
+	err = s.checkLinear(ctx, currBranch, targets[0])
+	if err != nil {
+		return err
+	}
+
+	mirrored := len(targets) > 1
+	if mirrored && !s.cfg.Push.BestEffort {
+		err = s.eachTarget(ctx, targets, func(ctx context.Context, t *pushTarget) error {
+			return s.git.CheckPush(ctx, t.remote, currBranch, t.branch, t.auth, forceWithLease)
+		})
 		if err != nil {
 			return err
 		}
-		s.passphrase = model.Password(passphrase)
-		providerAuth = &config.Credentials{
-			Token: s.passphrase.String(),
+		failed := []string{}
+		for _, target := range targets {
+			if target.err != nil {
+				utils.Logger(utils.LOG_FAILURE, fmt.Sprintf("%s: %v", target.remote.Name, target.err))
+				failed = append(failed, target.remote.Name)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%w, refused by %s and nothing was pushed", ErrPushFailed, strings.Join(failed, ", "))
 		}
-		pushErr = s.git.Push(remoteDetails, currBranch, providerAuth, force)
 	}
-	if pushErr != nil {
-		if errors.Is(pushErr, ErrKeyNotSupported) {
-			message := fmt.Sprintf("copy contents of %s.pub and upload the keys on %s", filepath.Join(os.Getenv("HOME"), gopushDir, keyName), remoteDetails.Provider().String())
+
+	progress := utils.NewProgress(model.StagePush.String())
+	err = s.eachTarget(ctx, targets, func(ctx context.Context, t *pushTarget) error {
+		if mirrored {
+			// concurrent progress reports would garble the status line
+			return s.git.Push(ctx, t.remote, currBranch, t.branch, tags, t.auth, forceWithLease, nil)
+		}
+		return s.git.Push(ctx, t.remote, currBranch, t.branch, tags, t.auth, forceWithLease, progress)
+	})
+	progress.Close()
+	if err != nil {
+		return err
+	}
+	pushErr := targets[0].err
+	for _, target := range targets {
+		if errors.Is(target.err, ErrKeyNotSupported) {
+			message := fmt.Sprintf("copy contents of %s.pub and upload the keys on %s", filepath.Join(os.Getenv("HOME"), gopushDir, keyName), target.provider.String())
 			utils.Logger(utils.LOG_STRICT_INFO, message)
 		}
-		if errors.Is(pushErr, ErrAlreadyUpToDate) {
-			utils.Logger(utils.LOG_SUCCESS, "already up-to-date")
+	}
+
+	if mirrored {
+		failed := failedTargets(targets)
+		if len(failed) > 0 && !s.cfg.Push.BestEffort {
+			if len(failed) < len(targets) {
+				utils.Logger(utils.LOG_WARNING, "the other remotes were pushed, the check before the push does not undo them")
+			}
+			return fmt.Errorf("%w on %s", ErrPushFailed, strings.Join(failed, ", "))
+		}
+		if len(failed) > 0 {
+			utils.Logger(utils.LOG_WARNING, fmt.Sprintf("%d of %d remotes failed", len(failed), len(targets)))
+		}
+		if pushErr != nil && !errors.Is(pushErr, ErrAlreadyUpToDate) {
 			return nil
 		}
-		return pushErr
+	} else if pushErr != nil {
+		if !errors.Is(pushErr, ErrAlreadyUpToDate) {
+			return pushErr
+		}
+		utils.Logger(utils.LOG_SUCCESS, "already up-to-date")
+	} else {
+		utils.Logger(utils.LOG_SUCCESS, "push successful")
 	}
-	utils.Logger(utils.LOG_SUCCESS, "push successful")
-	return err
+	return s.trackUpstream(currBranch, remoteDetails.Name, setUpstream)
 }
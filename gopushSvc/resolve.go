@@ -0,0 +1,291 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	resolveContinue = "\U00002714 continue"
+	resolveLater    = "quit, resolve later"
+
+	useOurs    = "keep ours"
+	useTheirs  = "take theirs"
+	editFile   = "edit in $EDITOR"
+	viewFile   = "view three-way diff"
+	backToList = "back"
+)
+
+// BeginRun remembers the branch and commit a run starts from, so that gopush
+// abort can go back to them when the run stops on conflicts, and the run can
+// be undone when its tests fail. The index is saved by StageChanges.
+func (s *Svc) BeginRun(ctx context.Context) error {
+	branch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	head, err := s.git.ResolveCommit("HEAD")
+	if errors.Is(err, ErrCommitNotFound) {
+		// nothing committed yet, nothing to go back to
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.checkpoint = &model.Checkpoint{Branch: branch, Head: head.Hash}
+	return nil
+}
+
+// UndoRun puts the branch back where the run started once generate or its
+// tests fail, so that nothing that failed stays committed. The changes the run
+// committed are left uncommitted in the working tree, staged as they were
+// before the run, and the changes pulled in are dropped. When the test stage
+// stopped for another reason, an interrupt or a timeout, the user is asked
+// first and the commits are kept outside a terminal.
+func (s *Svc) UndoRun(stopped error) error {
+	if s.checkpoint == nil {
+		return nil
+	}
+	if !errors.Is(stopped, ErrTestsFailed) && !errors.Is(stopped, ErrGenerateFailed) {
+		undo := false
+		if utils.IsTerminal() {
+			var err error
+			undo, err = utils.Confirm("Undo the commits of the run")
+			if err != nil {
+				return err
+			}
+		}
+		if !undo {
+			utils.Logger(utils.LOG_WARNING, "the commits of the run are kept, nothing was pushed")
+			return nil
+		}
+	}
+	err := s.git.RollbackRun(s.checkpoint)
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_WARNING, fmt.Sprintf("commits of the run undone, %s is back where it started", s.checkpoint.Branch))
+	return nil
+}
+
+// saveCheckpoint persists the start of the run once it stops on conflicts,
+// stopped being HEAD right before the pull.
+func (s *Svc) saveCheckpoint(stopped string) error {
+	if s.checkpoint == nil {
+		return nil
+	}
+	cp := *s.checkpoint
+	cp.Stopped = stopped
+	return s.git.SaveCheckpoint(&cp)
+}
+
+func conflictHelp(rebase bool) {
+	if rebase {
+		utils.Logger(utils.LOG_WARNING, `resolve the conflicts, then run "gopush rebase --continue", or "gopush abort" to go back to before the run`)
+		return
+	}
+	utils.Logger(utils.LOG_WARNING, `resolve the conflicts, then run "gopush continue", or "gopush abort" to go back to before the run`)
+}
+
+// ResolveConflicts walks the user through the files the pull left in
+// conflict, keeping either side, editing them or showing the three versions,
+// then finishes the merge or rebase. stopped is the error the pull stopped
+// with and is returned as is when the user leaves the conflicts for later.
+func (s *Svc) ResolveConflicts(ctx context.Context, stopped error) error {
+	rebase := errors.Is(stopped, ErrRebaseConflict)
+	if !utils.IsTerminal() {
+		conflictHelp(rebase)
+		return stopped
+	}
+	for {
+		conflicts, err := s.git.Conflicts()
+		if err != nil {
+			return err
+		}
+		done, err := s.resolveFiles(ctx, conflicts, rebase)
+		if err != nil {
+			return err
+		}
+		if !done {
+			conflictHelp(rebase)
+			return stopped
+		}
+		if !rebase {
+			return s.finishMerge()
+		}
+		err = s.continueRebase()
+		if !errors.Is(err, ErrRebaseConflict) {
+			return err
+		}
+		// the next commit stopped too, go around with its conflicts
+	}
+}
+
+// resolveFiles lets the user pick conflicting files until all of them are
+// resolved and continue is picked, or the user quits.
+func (s *Svc) resolveFiles(ctx context.Context, conflicts []*model.Conflict, rebase bool) (bool, error) {
+	resolved := make([]bool, len(conflicts))
+	for i, c := range conflicts {
+		if c.Kind != model.ConflictContent && c.Kind != model.ConflictAdded {
+			continue
+		}
+		markers, err := s.git.HasConflictMarkers(c.Path)
+		if err != nil {
+			return false, err
+		}
+		resolved[i] = !markers
+	}
+
+	ours, theirs := useOurs+" (local)", useTheirs+" (remote)"
+	if rebase {
+		// a rebase replays the local commits onto the remote ones
+		ours, theirs = useOurs+" (remote)", useTheirs+" (local commit)"
+	}
+	for {
+		items := make([]string, 0, len(conflicts)+2)
+		all := true
+		for i, c := range conflicts {
+			mark := "[ ]"
+			if resolved[i] {
+				mark = "[x]"
+			}
+			items = append(items, fmt.Sprintf("%s %s", mark, c))
+			all = all && resolved[i]
+		}
+		if all {
+			items = append(items, resolveContinue)
+		}
+		items = append(items, resolveLater)
+
+		choice, err := utils.Select("Resolve conflicts", items)
+		if err != nil {
+			return false, err
+		}
+		switch choice {
+		case resolveContinue:
+			return true, nil
+		case resolveLater:
+			return false, nil
+		}
+		i := 0
+		for i < len(conflicts) && items[i] != choice {
+			i++
+		}
+		c := conflicts[i]
+
+	actions:
+		for {
+			action, err := utils.Select(c.Path, []string{ours, theirs, editFile, viewFile, backToList})
+			if err != nil {
+				return false, err
+			}
+			switch action {
+			case ours, theirs:
+				err = s.git.ResolveConflict(c.Path, action == theirs)
+				if err != nil {
+					return false, err
+				}
+				resolved[i] = true
+				utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("%s resolved", c.Path))
+				break actions
+			case editFile:
+				err = s.bash.OpenEditor(ctx, c.Path)
+				if err != nil {
+					return false, err
+				}
+				markers, err := s.git.HasConflictMarkers(c.Path)
+				if err != nil {
+					return false, err
+				}
+				resolved[i] = !markers
+				if markers {
+					utils.Logger(utils.LOG_WARNING, fmt.Sprintf("%s still has conflict markers", c.Path))
+					continue
+				}
+				utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("%s resolved", c.Path))
+				break actions
+			case viewFile:
+				view, err := s.git.ConflictView(c.Path)
+				if err != nil {
+					return false, err
+				}
+				fmt.Print(view)
+			default:
+				break actions
+			}
+		}
+	}
+}
+
+// Continue finishes the merge or rebase a stopped gopush run left in progress
+// once its conflicts are resolved, then forgets where the run started.
+func (s *Svc) Continue() error {
+	state, err := s.git.RebaseState()
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		return s.RebaseContinue()
+	}
+	err = s.finishMerge()
+	if errors.Is(err, ErrConflictsRemain) {
+		conflictHelp(false)
+	}
+	return err
+}
+
+// Abort stops the merge or rebase a run left in progress and moves the branch
+// back to where the run started, leaving the run's changes uncommitted in the
+// working tree.
+func (s *Svc) Abort() error {
+	aborted := false
+	state, err := s.git.RebaseState()
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		err = s.RebaseAbort()
+		if err != nil {
+			return err
+		}
+		aborted = true
+	} else {
+		err = s.git.MergeAbort()
+		if err != nil && !errors.Is(err, ErrNoMerge) {
+			return err
+		}
+		if err == nil {
+			utils.Logger(utils.LOG_SUCCESS, "merge aborted")
+			aborted = true
+		}
+	}
+
+	cp, err := s.git.Checkpoint()
+	if err != nil {
+		return err
+	}
+	if cp == nil {
+		if !aborted {
+			return ErrNothingToAbort
+		}
+		return nil
+	}
+	head, err := s.git.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+	if head.Hash != cp.Stopped {
+		utils.Logger(utils.LOG_WARNING, "the branch moved since the run stopped, it is left as is")
+		return s.git.RemoveCheckpoint()
+	}
+	err = s.git.RestoreCheckpoint(cp)
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("back on %s as before the run, its changes are left uncommitted", cp.Branch))
+	return s.git.RemoveCheckpoint()
+}
@@ -0,0 +1,57 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// CheckRewrite refuses to rewrite the commit at rev, by amending it or
+// squashing a fixup into it, once it is on the remote branch. With
+// forceWithLease the rewrite is allowed and only warned about, except on
+// protected branches.
+func (s *Svc) CheckRewrite(ctx context.Context, rev string, forceWithLease bool) error {
+	branch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	remoteDetails, err := s.git.GetRemoteDetails()
+	if err != nil {
+		return err
+	}
+	remoteBranch, err := s.remoteBranch(branch, remoteDetails.Name, false)
+	if err != nil {
+		return err
+	}
+	pushed, err := s.git.IsPushed(remoteDetails.Name, remoteBranch, rev)
+	if err != nil || !pushed {
+		return err
+	}
+	if !forceWithLease {
+		return fmt.Errorf("%w: %s", ErrRewritePushed, rev)
+	}
+	if s.protected(branch) {
+		return fmt.Errorf("%w: %s", ErrProtectedBranch, branch)
+	}
+	utils.Logger(utils.LOG_WARNING, fmt.Sprintf("%s is already on %s/%s, rewriting it needs a forced push", rev, remoteDetails.Name, remoteBranch))
+	return nil
+}
+
+// autosquash folds the fixup commit just made into target. When that is not
+// possible without conflicts the fixup commit is kept for a manual
+// git rebase -i --autosquash.
+func (s *Svc) autosquash(target *model.Commit, committer *model.Identity) error {
+	err := s.git.Autosquash(target.Hash, committer)
+	if errors.Is(err, ErrAutosquashFailed) {
+		utils.Logger(utils.LOG_WARNING, fmt.Sprintf("fixup commit kept, commits after %s change the same files or merge, squash it with git rebase -i --autosquash", target.ShortHash()))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_SUCCESS, fmt.Sprintf("fixup squashed into %s", target.ShortHash()))
+	return nil
+}
@@ -0,0 +1,216 @@
+package gopushSvc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	allowlistFile   = ".gopushallow"
+	allowAnnotation = "gopush:allow"
+)
+
+type secretRule struct {
+	name    string
+	pattern *regexp.Regexp
+	// entropy is the minimum shannon entropy the first non-empty submatch (or
+	// the whole match) needs to be reported, zero reports every match.
+	entropy float64
+}
+
+// secretRules are checked in order, entropy based rules must come last.
+var secretRules = []secretRule{
+	{name: "aws access key id", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{name: "aws secret access key", pattern: regexp.MustCompile(`(?i)aws.{0,20}(?:secret|key).{0,20}['"]([0-9a-zA-Z/+]{40})['"]`)},
+	{name: "github token", pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{name: "gitlab token", pattern: regexp.MustCompile(`\bgl(?:pat|dt|rt|ptt)-[A-Za-z0-9_\-]{20,}\b`)},
+	{name: "private key", pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY(?: BLOCK)?-----`)},
+	// unquoted values stop at "." and "(" so that selector and call chains in
+	// source code, like s.cfg.ProviderAuth(...), are not taken for secrets
+	{name: "high entropy secret", pattern: regexp.MustCompile(`(?i)(?:key|secret|token|passw(?:or)?d|pwd|auth)[\w.\-]*["']?\s*[:=]+\s*(?:["']([A-Za-z0-9+/=_\-.]{16,})["']|([A-Za-z0-9+/=_\-]{16,})(?:$|[^A-Za-z0-9+/=_\-.(]))`), entropy: 3.5},
+	{name: "high entropy string", pattern: regexp.MustCompile(`["'\x60]([A-Za-z0-9+/=_\-]{32,})["'\x60]`), entropy: 4.5},
+}
+
+type secretFinding struct {
+	path string
+	line int
+	rule string
+}
+
+type allowlist struct {
+	paths   []string
+	matches []*regexp.Regexp
+}
+
+// readAllowlist loads the repo allowlist, "path:<glob>" lines skip whole files,
+// every other line is a regexp that skips the secrets it matches.
+func readAllowlist() (*allowlist, error) {
+	a := &allowlist{}
+	f, err := os.Open(allowlistFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if glob, ok := strings.CutPrefix(line, "path:"); ok {
+			a.paths = append(a.paths, strings.TrimSpace(glob))
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", allowlistFile, err)
+		}
+		a.matches = append(a.matches, re)
+	}
+	return a, scanner.Err()
+}
+
+func (a *allowlist) allowsPath(p string) bool {
+	for _, glob := range a.paths {
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, path.Base(p)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *allowlist) allowsMatch(m string) bool {
+	for _, re := range a.matches {
+		if re.MatchString(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// userTokens returns the credentials from the gopush config so they are caught
+// even when they do not look like a known token format.
+func (s *Svc) userTokens() []string {
+	tokens := []string{}
+	if s.cfg == nil {
+		return tokens
+	}
+	for _, p := range []model.Provider{model.ProviderGITHUB, model.ProviderGITLAB, model.ProviderBITBUCKET, model.ProviderGITEA} {
+		if cred := s.cfg.ProviderAuth(p); cred != nil && len(cred.Token) >= 8 {
+			tokens = append(tokens, cred.Token)
+		}
+	}
+	return tokens
+}
+
+func scanLine(text string, tokens []string, allow *allowlist) []string {
+	found := []string{}
+	for _, token := range tokens {
+		if strings.Contains(text, token) && !allow.allowsMatch(token) {
+			found = append(found, "gopush credential")
+		}
+	}
+	for _, rule := range secretRules {
+		// entropy rules only catch what the specific formats missed
+		if rule.entropy > 0 && len(found) > 0 {
+			break
+		}
+		for _, m := range rule.pattern.FindAllStringSubmatch(text, -1) {
+			secret := m[0]
+			for _, sub := range m[1:] {
+				if sub != "" {
+					secret = sub
+					break
+				}
+			}
+			if rule.entropy > 0 && shannonEntropy(secret) < rule.entropy {
+				continue
+			}
+			if allow.allowsMatch(secret) {
+				continue
+			}
+			found = append(found, rule.name)
+			break
+		}
+	}
+	return found
+}
+
+// scanSecrets checks the lines added by the staged changes for credentials.
+func (s *Svc) scanSecrets() ([]*secretFinding, error) {
+	allow, err := readAllowlist()
+	if err != nil {
+		return nil, err
+	}
+	diffs, err := s.git.StagedAdditions()
+	if err != nil {
+		return nil, err
+	}
+	tokens := s.userTokens()
+
+	findings := []*secretFinding{}
+	for _, fd := range diffs {
+		if allow.allowsPath(fd.Path) {
+			continue
+		}
+		for _, line := range fd.Added {
+			if strings.Contains(line.Text, allowAnnotation) {
+				continue
+			}
+			for _, rule := range scanLine(line.Text, tokens, allow) {
+				findings = append(findings, &secretFinding{path: fd.Path, line: line.Number, rule: rule})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// checkSecrets blocks the commit when the staged changes contain credentials.
+func (s *Svc) checkSecrets() error {
+	findings, err := s.scanSecrets()
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		utils.Logger(utils.LOG_SUCCESS, "no secrets found")
+		return nil
+	}
+	for _, f := range findings {
+		utils.Logger(utils.LOG_WARNING, fmt.Sprintf("%s:%d %s", f.path, f.line, f.rule))
+	}
+	utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("remove them, mark the line with %q or add them to %s", allowAnnotation, allowlistFile))
+	return ErrSecretsFound
+}
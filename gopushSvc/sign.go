@@ -0,0 +1,94 @@
+package gopushSvc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// signing merges the gopush signing config over the one in git config, nil
+// means commits are not signed. OpenPGP signing needs the keyring in
+// Signing.Key: without it commit.gpgsign alone is ignored with a warning,
+// while Signing.Enabled fails.
+func (s *Svc) signing() (*model.Signing, error) {
+	sig, enabled, err := s.git.SigningConfig()
+	if err != nil {
+		return nil, err
+	}
+	required := false
+	if s.cfg != nil {
+		c := s.cfg.Signing
+		required = c.Enabled
+		enabled = enabled || c.Enabled
+		if c.Format != "" && model.SignFormat(c.Format) != sig.Format {
+			// the key in git config belongs to the other format
+			sig = &model.Signing{Format: model.SignFormat(c.Format)}
+		}
+		if c.Key != "" {
+			sig.Key = c.Key
+		}
+		if c.KeyID != "" {
+			sig.KeyID = c.KeyID
+		}
+	}
+	if !enabled {
+		return nil, nil
+	}
+	if sig.Format == model.SignSSH && sig.Key == "" {
+		sig.Key = gopushKeyPath()
+	}
+	if sig.Format == model.SignOpenPGP && sig.Key == "" {
+		// gpg finds keys on its own, gopush needs the keyring file
+		if required {
+			return nil, fmt.Errorf("%w: set Signing.Key to an armored openpgp keyring", ErrSignKeyNotFound)
+		}
+		if !s.signWarned {
+			utils.Logger(utils.LOG_WARNING, "commit.gpgsign is set but Signing.Key names no openpgp keyring, commits are not signed")
+			s.signWarned = true
+		}
+		return nil, nil
+	}
+	return sig, nil
+}
+
+func gopushKeyPath() string {
+	return filepath.Join(os.Getenv("HOME"), gopushDir, keyName)
+}
+
+// loadSigner sets up commit signing when it is enabled, asking for the key
+// passphrase if the key is encrypted. The gopush key shares its passphrase
+// with ssh auth.
+func (s *Svc) loadSigner() error {
+	sig, err := s.signing()
+	if err != nil || sig == nil {
+		return err
+	}
+	gopushKey := sig.Key == gopushKeyPath()
+	passphrase := ""
+	if gopushKey {
+		passphrase = s.passphrase.String()
+	}
+	err = s.git.SetSigner(sig, passphrase)
+	for errors.Is(err, ErrInvalidPassphrase) {
+		label := "signing key passphrase"
+		if passphrase != "" {
+			label = "invalid passphrase"
+		}
+		passphrase, err = utils.Prompt(true, false, label)
+		if err != nil {
+			return err
+		}
+		err = s.git.SetSigner(sig, passphrase)
+	}
+	if err != nil {
+		return err
+	}
+	if gopushKey && passphrase != "" {
+		s.passphrase = model.Password(passphrase)
+	}
+	return nil
+}
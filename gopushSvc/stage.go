@@ -0,0 +1,32 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seriouspoop/gopush/model"
+)
+
+// stageContext derives the context a pipeline stage runs with, bounded by the
+// stage timeout from config when one is set.
+func (s *Svc) stageContext(ctx context.Context, stage model.Stage) (context.Context, context.CancelFunc) {
+	if s.cfg != nil {
+		if timeout := s.cfg.StageTimeout(stage); timeout > 0 {
+			return context.WithTimeout(ctx, timeout)
+		}
+	}
+	return context.WithCancel(ctx)
+}
+
+// stageError reports err as an interrupt or timeout of stage when ctx ended
+// before the stage could finish.
+func stageError(ctx context.Context, stage model.Stage, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w during %s stage", ErrStageTimeout, stage)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("%w during %s stage", ErrInterrupted, stage)
+	}
+	return err
+}
@@ -10,6 +10,10 @@ type Svc struct {
 	bash       scriptHelper
 	cfg        *config.Config
 	passphrase model.Password
+	// checkpoint is where the current run started from.
+	checkpoint *model.Checkpoint
+	// signWarned is set once the unusable commit.gpgsign was reported.
+	signWarned bool
 }
 
 func New(git gitHelper, bash scriptHelper) *Svc {
@@ -1,22 +1,216 @@
 package gopushSvc
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/seriouspoop/gopush/model"
 	"github.com/seriouspoop/gopush/utils"
 )
 
-func (s *Svc) CheckTestsAndRun() (bool, error) {
-	present, err := s.bash.TestsPresent()
+const (
+	logDir      = "logs"
+	worktreeDir = "."
+)
+
+// stageLog creates the file that keeps the full output of the latest run of stage.
+func (s *Svc) stageLog(stage model.Stage) (*os.File, error) {
+	gopushDirPath, err := s.createConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if !s.bash.Exists(gopushDirPath, logDir) {
+		err := s.bash.CreateDir(gopushDirPath, logDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.bash.CreateFile(filepath.Join(gopushDirPath, logDir), fmt.Sprintf("%s.log", stage))
+}
+
+// runStage runs fn within the stage context. Output is always kept in the
+// stage log and, when streaming, shown live as it is produced.
+func (s *Svc) runStage(ctx context.Context, stage model.Stage, stream bool, fn func(context.Context, io.Writer) (string, error)) (string, string, error) {
+	stageCtx, cancel := s.stageContext(ctx, stage)
+	defer cancel()
+
+	logFile, err := s.stageLog(stage)
+	if err != nil {
+		return "", "", err
+	}
+	defer logFile.Close()
+
+	var w io.Writer = logFile
+	if stream {
+		st := utils.NewStream(stage.String())
+		defer st.Close()
+		w = io.MultiWriter(logFile, st)
+	}
+
+	output, err := fn(stageCtx, w)
+	if stageCtx.Err() != nil {
+		return output, logFile.Name(), stageError(stageCtx, stage, err)
+	}
+	return output, logFile.Name(), err
+}
+
+func stageAborted(err error) bool {
+	return errors.Is(err, ErrInterrupted) || errors.Is(err, ErrStageTimeout)
+}
+
+// worktreeSnapshot maps every changed tracked file to a digest of its content.
+func (s *Svc) worktreeSnapshot() (map[string]string, error) {
+	changes, err := s.git.Status()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := map[string]string{}
+	for _, change := range changes {
+		if !change.Tracked() {
+			continue
+		}
+		snapshot[change.Path] = fileDigest(change.Path)
+	}
+	return snapshot, nil
+}
+
+func fileDigest(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// generate runs go generate on the configured packages in dir when enabled.
+// When dir is the worktree it warns about tracked files generation rewrote
+// that were not part of the staged changes.
+func (s *Svc) generate(ctx context.Context, dir string, stream bool) error {
+	if s.cfg == nil || !s.cfg.Generate.Enabled {
+		return nil
+	}
+	worktree := dir == worktreeDir
+	before := map[string]string{}
+	if worktree {
+		var err error
+		before, err = s.worktreeSnapshot()
+		if err != nil {
+			return err
+		}
+	}
+
+	output, logPath, err := s.runStage(ctx, model.StageGenerate, stream, func(ctx context.Context, w io.Writer) (string, error) {
+		return s.bash.GenerateMocks(ctx, dir, s.cfg.Generate.Packages, w)
+	})
+	if stageAborted(err) {
+		return err
+	}
+	if err != nil {
+		if !stream {
+			utils.Logger(utils.LOG_FAILURE, output)
+		}
+		utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("full generate log at %s", logPath))
+		return ErrGenerateFailed
+	}
+	if !worktree {
+		utils.Logger(utils.LOG_SUCCESS, "generate done")
+		return nil
+	}
+
+	after, err := s.worktreeSnapshot()
+	if err != nil {
+		return err
+	}
+	dirtied := []string{}
+	for path, digest := range after {
+		if prev, ok := before[path]; !ok || prev != digest {
+			dirtied = append(dirtied, path)
+		}
+	}
+	if len(dirtied) > 0 {
+		sort.Strings(dirtied)
+		utils.Logger(utils.LOG_WARNING, fmt.Sprintf("go generate changed tracked files that are not staged: %s", strings.Join(dirtied, ", ")))
+	}
+	utils.Logger(utils.LOG_SUCCESS, "generate done")
+	return nil
+}
+
+// snapshotDir exports the staged index into a temporary directory, the
+// returned func removes it.
+func (s *Svc) snapshotDir() (string, func(), error) {
+	dir, err := os.MkdirTemp("", "gopush-snapshot-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	err = s.git.ExportIndex(dir)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+// CheckTestsAndRun runs generate and tests on the worktree, or on a checkout of
+// the index when snapshot is set. gopush run calls it once the changes are
+// committed and pulled, so the result applies to what gets pushed.
+// Finding no tests fails on branches whose policy requires them.
+func (s *Svc) CheckTestsAndRun(ctx context.Context, stream, snapshot bool) (bool, error) {
+	if s.cfg != nil {
+		stream = stream || s.cfg.Output.Stream
+		snapshot = snapshot || s.cfg.Test.Snapshot
+	}
+	dir := worktreeDir
+	if snapshot {
+		snapDir, cleanup, err := s.snapshotDir()
+		if err != nil {
+			return false, err
+		}
+		defer cleanup()
+		dir = snapDir
+		utils.Logger(utils.LOG_SUCCESS, "staged snapshot exported")
+	}
+
+	err := s.generate(ctx, dir, stream)
 	if err != nil {
 		return false, err
 	}
+
+	present, err := s.bash.TestsPresent(ctx, dir)
+	if err != nil {
+		return false, stageError(ctx, model.StageTest, err)
+	}
 	if present {
-		s.bash.GenerateMocks()
-		output, err := s.bash.RunTests()
+		output, logPath, err := s.runStage(ctx, model.StageTest, stream, func(ctx context.Context, w io.Writer) (string, error) {
+			return s.bash.RunTests(ctx, dir, w)
+		})
+		if stageAborted(err) {
+			return false, err
+		}
 		if err != nil {
-			utils.Logger(utils.LOG_FAILURE, output)
+			if !stream {
+				utils.Logger(utils.LOG_FAILURE, output)
+			}
+			utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("full test log at %s", logPath))
 			return false, ErrTestsFailed
 		}
 		return true, nil
 	}
+	branch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return false, err
+	}
+	if s.branchPolicy(branch).RequireTests {
+		return false, fmt.Errorf("%w: no tests found on %s", ErrTestsRequired, branch)
+	}
 	return false, nil
 }
@@ -0,0 +1,144 @@
+package gopushSvc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+const (
+	ticketFooter = "footer"
+	ticketScope  = "scope"
+	ticketPrefix = "prefix"
+)
+
+// branchTicket extracts the issue key from the current branch name, it is
+// empty when no pattern is configured or the branch holds none.
+func (s *Svc) branchTicket(ctx context.Context) (string, error) {
+	if s.cfg == nil || s.cfg.Ticket.Pattern == "" {
+		return "", nil
+	}
+	branch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return "", err
+	}
+	ticket, _, err := s.findTicket(branch.String())
+	if err != nil {
+		return "", err
+	}
+	if ticket == "" && s.cfg.Ticket.Required {
+		utils.Logger(utils.LOG_WARNING, fmt.Sprintf("branch %q has no ticket matching %s", branch, s.cfg.Ticket.Pattern))
+	}
+	return ticket, nil
+}
+
+// findTicket returns the issue key in text and the whole match of the ticket
+// pattern, both empty when there is none.
+func (s *Svc) findTicket(text string) (ticket, match string, err error) {
+	if s.cfg == nil || s.cfg.Ticket.Pattern == "" {
+		return "", "", nil
+	}
+	re, err := regexp.Compile(s.cfg.Ticket.Pattern)
+	if err != nil {
+		return "", "", fmt.Errorf("ticket pattern: %w", err)
+	}
+	m := re.FindStringSubmatch(text)
+	if m == nil {
+		return "", "", nil
+	}
+	if len(m) > 1 && m[1] != "" {
+		return m[1], m[0], nil
+	}
+	return m[0], m[0], nil
+}
+
+func (s *Svc) ticketPlacement() string {
+	if s.cfg == nil || s.cfg.Ticket.Placement == "" {
+		return ticketFooter
+	}
+	return s.cfg.Ticket.Placement
+}
+
+// applyTicket places ticket in msg as scope, subject prefix or Refs footer.
+func (s *Svc) applyTicket(msg *model.CommitMessage, ticket string) {
+	msg.Ticket = ticket
+	if ticket == "" {
+		return
+	}
+	switch s.ticketPlacement() {
+	case ticketScope:
+		if msg.Scope == "" {
+			msg.Scope = ticket
+		}
+	case ticketPrefix:
+		if !strings.HasPrefix(msg.Subject, ticket) {
+			msg.Subject = fmt.Sprintf("%s %s", ticket, msg.Subject)
+		}
+	default:
+		if !slices.Contains(msg.Refs, ticket) {
+			msg.Refs = append(msg.Refs, ticket)
+		}
+	}
+}
+
+// ticketMessage injects ticket into a message passed with -m, leaving it as is
+// when it already mentions the ticket. The header is spliced rather than
+// rebuilt, so that the rest of it stays as the user wrote it.
+func (s *Svc) ticketMessage(message, ticket string) string {
+	if ticket == "" || strings.Contains(message, ticket) {
+		return message
+	}
+	message = strings.TrimRight(message, "\n")
+	switch placement := s.ticketPlacement(); placement {
+	case ticketScope, ticketPrefix:
+		msg, ok := model.ParseCommitMessage(message)
+		if !ok {
+			return message
+		}
+		header, rest, found := strings.Cut(message, "\n")
+		switch {
+		case placement == ticketPrefix:
+			// the subject ends the header
+			at := len(header) - len(msg.Subject)
+			header = fmt.Sprintf("%s%s %s", header[:at], ticket, header[at:])
+		case msg.Scope == "":
+			// the type starts the header
+			at := len(msg.Type)
+			header = fmt.Sprintf("%s(%s)%s", header[:at], ticket, header[at:])
+		}
+		if !found {
+			return header
+		}
+		return fmt.Sprintf("%s\n%s", header, rest)
+	default:
+		return appendTrailer(message, "Refs", ticket)
+	}
+}
+
+// isTicketScope reports whether scope is a ticket put in as the scope, which
+// the configured scopes do not list.
+func (s *Svc) isTicketScope(scope string) bool {
+	if s.ticketPlacement() != ticketScope {
+		return false
+	}
+	ticket, match, err := s.findTicket(scope)
+	return err == nil && ticket != "" && (ticket == scope || match == scope)
+}
+
+// trimTicketPrefix drops a leading ticket from subject so rules like
+// subject-case look at the words the user wrote.
+func (s *Svc) trimTicketPrefix(subject string) string {
+	if s.cfg == nil || s.cfg.Ticket.Pattern == "" {
+		return subject
+	}
+	re, err := regexp.Compile(`^(?:` + s.cfg.Ticket.Pattern + `)\s+`)
+	if err != nil {
+		return subject
+	}
+	return re.ReplaceAllString(subject, "")
+}
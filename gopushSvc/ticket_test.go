@@ -0,0 +1,51 @@
+package gopushSvc
+
+import (
+	"testing"
+
+	"github.com/seriouspoop/gopush/config"
+)
+
+func ticketSvc(placement string) *Svc {
+	cfg := &config.Config{}
+	cfg.Ticket.Pattern = `[A-Z][A-Z0-9]+-[0-9]+`
+	cfg.Ticket.Placement = placement
+	cfg.Commit.Scopes = []string{"api", "cli"}
+	return &Svc{cfg: cfg}
+}
+
+func TestTicketMessage(t *testing.T) {
+	tests := []struct {
+		placement string
+		message   string
+		want      string
+	}{
+		{ticketScope, "feat: add thing", "feat(PROJ-1): add thing"},
+		{ticketScope, "feat(api): add thing", "feat(api): add thing"},
+		{ticketScope, "feat!: drop thing", "feat(PROJ-1)!: drop thing"},
+		{ticketScope, "feat: drop thing\n\nBREAKING CHANGE: gone", "feat(PROJ-1): drop thing\n\nBREAKING CHANGE: gone"},
+		{ticketPrefix, "fix(api): null deref\n\nBREAKING CHANGE: gone", "fix(api): PROJ-1 null deref\n\nBREAKING CHANGE: gone"},
+		{ticketPrefix, "fix: PROJ-1 null deref", "fix: PROJ-1 null deref"},
+		{ticketFooter, "fix: null deref", "fix: null deref\n\nRefs: PROJ-1"},
+		{ticketScope, "not conventional", "not conventional"},
+	}
+	for _, tt := range tests {
+		got := ticketSvc(tt.placement).ticketMessage(tt.message, "PROJ-1")
+		if got != tt.want {
+			t.Errorf("ticketMessage(%q) with %s = %q, want %q", tt.message, tt.placement, got, tt.want)
+		}
+	}
+}
+
+func TestLintTicketScope(t *testing.T) {
+	s := ticketSvc(ticketScope)
+	if problems := s.lintCommitMsg("feat(PROJ-1): add thing"); len(problems) > 0 {
+		t.Errorf("ticket scope rejected: %v", problems)
+	}
+	if problems := s.lintCommitMsg("feat(web): add thing"); len(problems) == 0 {
+		t.Error("unknown scope accepted")
+	}
+	if problems := ticketSvc(ticketFooter).lintCommitMsg("feat(PROJ-1): add thing"); len(problems) == 0 {
+		t.Error("ticket scope accepted without the scope placement")
+	}
+}
@@ -0,0 +1,73 @@
+package gopushSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seriouspoop/gopush/model"
+	"github.com/seriouspoop/gopush/utils"
+)
+
+// trackUpstream makes branch track its counterpart on remote once it has been
+// pushed there, keeping an existing upstream unless force is set.
+func (s *Svc) trackUpstream(branch model.Branch, remote string, force bool) error {
+	upstream, err := s.git.Upstream(branch)
+	if err != nil {
+		return err
+	}
+	if upstream != nil && !force {
+		return nil
+	}
+	upstream = &model.Upstream{Remote: remote, Branch: branch}
+	err = s.git.SetUpstream(branch, upstream)
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s tracks %s", branch, upstream))
+	return nil
+}
+
+// remoteBranch is the branch of remote that branch is pushed to: its upstream
+// when branch tracks one on remote, else the branch of the same name. With
+// setUpstream the upstream is about to be replaced, so the same name is used.
+func (s *Svc) remoteBranch(branch model.Branch, remote string, setUpstream bool) (model.Branch, error) {
+	if setUpstream {
+		return branch, nil
+	}
+	upstream, err := s.git.Upstream(branch)
+	if err != nil {
+		return "", err
+	}
+	if upstream == nil || upstream.Remote != remote {
+		return branch, nil
+	}
+	return upstream.Branch, nil
+}
+
+// TrackingStatus shows how many commits the current branch is ahead of and
+// behind its upstream, as of the last fetch.
+func (s *Svc) TrackingStatus(ctx context.Context) error {
+	branch, err := s.bash.GetCurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+	upstream, err := s.git.Upstream(branch)
+	if err != nil {
+		return err
+	}
+	if upstream == nil {
+		utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s has no upstream yet", branch))
+		return nil
+	}
+	ahead, behind, err := s.git.AheadBehind(branch, upstream)
+	if errors.Is(err, ErrRemoteBranchNotFound) {
+		utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%s is not on %s yet", upstream.Branch, upstream.Remote))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	utils.Logger(utils.LOG_STRICT_INFO, fmt.Sprintf("%d ahead, %d behind %s", ahead, behind, upstream))
+	return nil
+}
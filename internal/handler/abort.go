@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/gopushSvc"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+func Abort(s servicer) *cobra.Command {
+	abortCmd := &cobra.Command{
+		Use:   "abort",
+		Short: "goes back to before a run that stopped on conflicts",
+		Long: heredoc.Doc(`
+			Aborts the merge or rebase a stopped gopush run left in progress and
+			moves the branch back to where the run started. Changes the run
+			committed are left in the working tree, uncommitted.
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+			err := s.LoadProject()
+			if err != nil {
+				return err
+			}
+			err = s.LoadConfig()
+			if err != nil && !errors.Is(err, gopushSvc.ErrFileNotFound) {
+				return err
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.Abort()
+		},
+	}
+	return abortCmd
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/gopushSvc"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+func Branch(s servicer) *cobra.Command {
+	branchCmd := &cobra.Command{
+		Use:   "branch",
+		Short: "builds a branch name from the branch template and switches to it",
+		Long: heredoc.Doc(`
+			branch asks for the type of change, the ticket and a short description,
+			then creates and checks out the branch named by the branch template,
+			e.g. jdoe/feat/PROJ-12-add-login for "{prefix}/{type}/{ticket}-{slug}".
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+			err := s.LoadProject()
+			if err != nil {
+				return err
+			}
+			// the default template applies without a config
+			err = s.LoadConfig()
+			if err != nil && !errors.Is(err, gopushSvc.ErrFileNotFound) {
+				return err
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.BuildBranch()
+		},
+	}
+	return branchCmd
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/gopushSvc"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+func Changelog(s servicer) *cobra.Command {
+	dryRun := false
+	changelogCmd := &cobra.Command{
+		Use:   "changelog",
+		Short: "writes CHANGELOG.md from the conventional commits between release tags",
+		Long: heredoc.Doc(`
+			changelog lists the conventional commits of every vX.Y.Z tag reachable from
+			HEAD, and those not released yet, grouped by commit type. It is written in the
+			Keep a Changelog format unless a template is configured, and rendered again
+			from the history each time.
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+			err := s.LoadProject()
+			if err != nil {
+				return err
+			}
+			err = s.LoadConfig()
+			if err != nil && !errors.Is(err, gopushSvc.ErrFileNotFound) {
+				return err
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.Changelog(dryRun)
+		},
+	}
+	changelogCmd.Flags().BoolVar(&dryRun, dryRunFlag, false, "print the changelog instead of writing it")
+	return changelogCmd
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/gopushSvc"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+func Continue(s servicer) *cobra.Command {
+	continueCmd := &cobra.Command{
+		Use:   "continue",
+		Short: "finishes a run that stopped on conflicts",
+		Long: heredoc.Doc(`
+			Commits the merge a stopped gopush run left in progress, with the
+			working tree version of the conflicting files, or goes on with the
+			stopped rebase. Files still holding conflict markers are refused.
+			Run gopush run again afterwards to push.
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+			err := s.LoadProject()
+			if err != nil {
+				return err
+			}
+			err = s.LoadConfig()
+			if err != nil && !errors.Is(err, gopushSvc.ErrFileNotFound) {
+				return err
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.Continue()
+		},
+	}
+	return continueCmd
+}
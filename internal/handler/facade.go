@@ -1,6 +1,10 @@
 package handler
 
-import "github.com/seriouspoop/gopush/model"
+import (
+	"context"
+
+	"github.com/seriouspoop/gopush/model"
+)
 
 type servicer interface {
 	LoadProject() error
@@ -10,11 +14,27 @@ type servicer interface {
 	SetRemoteHTTPAuth() error
 	LoadConfig() error
 	// FetchAndMerge() error
-	Pull(force bool) error
-	StageChanges() error
+	Pull(ctx context.Context, force, rebase, forceWithLease bool) error
+	RebaseContinue() error
+	RebaseAbort() error
+	BeginRun(ctx context.Context) error
+	ResolveConflicts(ctx context.Context, stopped error) error
+	Continue() error
+	Abort() error
+	UndoRun(stopped error) error
+	StageChanges(ctx context.Context, all, patch bool) error
+	Commit(ctx context.Context, message string, coAuthors []string, amend bool, fixup string) error
+	CheckRewrite(ctx context.Context, rev string, forceWithLease bool) error
+	Pair(coAuthors []string, clear bool) error
+	LintCommitMsg(text string) error
 	SwitchBranchIfExists(branch model.Branch) (bool, error)
 	CreateBranchAndSwitch(branch model.Branch) error
-	CheckTestsAndRun() (bool, error)
-	Push(setUpstreamBranch bool) error
-	SetRemoteSSHAuth() error
+	BuildBranch() error
+	CheckPolicy(ctx context.Context, skipTests bool) error
+	CheckTestsAndRun(ctx context.Context, stream, snapshot bool) (bool, error)
+	Push(ctx context.Context, setUpstreamBranch, forceWithLease bool) error
+	TrackingStatus(ctx context.Context) error
+	Release(ctx context.Context, bump string, dryRun, changelog bool) error
+	Changelog(dryRun bool) error
+	SetRemoteSSHAuth(ctx context.Context) error
 }
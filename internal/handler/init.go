@@ -13,6 +13,7 @@ import (
 func Init(s servicer) *cobra.Command {
 	// TODO -> verbose implementation
 	// var verbose bool
+	all := false
 	initCmd := &cobra.Command{
 		Use:   "init",
 		Short: "initializes git repo with all the config setting",
@@ -62,7 +63,7 @@ func Init(s servicer) *cobra.Command {
 			err = s.SetRemoteHTTPAuth()
 			if err != nil {
 				if errors.Is(err, gopushSvc.ErrInvalidAuthMethod) {
-					err := s.SetRemoteSSHAuth()
+					err := s.SetRemoteSSHAuth(cmd.Context())
 					if err != nil {
 						if errors.Is(err, gopushSvc.ErrWaitExit) {
 							return nil
@@ -82,13 +83,17 @@ func Init(s servicer) *cobra.Command {
 
 			// staging current files
 			utils.Logger(utils.LOG_INFO, "Staging changes...")
-			err = s.StageChanges()
+			err = s.StageChanges(cmd.Context(), all, false)
+			if err != nil {
+				return err
+			}
+			err = s.Commit(cmd.Context(), "", nil, false, "")
 			if err != nil {
 				return err
 			}
 
 			utils.Logger(utils.LOG_INFO, "Pulling commits from main...")
-			err = s.Pull(true)
+			err = s.Pull(cmd.Context(), true, false, false)
 			if err != nil {
 				if errors.Is(err, gopushSvc.ErrPullFailed) {
 					utils.Logger(utils.LOG_INFO, "Remote pull failed, try pulling manually.")
@@ -103,6 +108,7 @@ func Init(s servicer) *cobra.Command {
 		},
 	}
 
+	initCmd.PersistentFlags().BoolVarP(&all, "all", "a", false, "stage every changed file without asking")
 	// initCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "detailed output for each step")
 
 	return initCmd
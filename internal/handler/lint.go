@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/gopushSvc"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+func LintCommit(s servicer) *cobra.Command {
+	lintCmd := &cobra.Command{
+		Use:   "lint-commit <file>",
+		Short: "checks a commit message against the commit rules",
+		Long: heredoc.Doc(`
+			lint-commit checks the commit message in <file> ("-" reads stdin) against
+			Conventional Commits and the [Commit] and [Lint] sections of the config.
+
+			Install it as a commit-msg hook for plain git commits:
+
+			  printf '#!/bin/sh\nexec gopush lint-commit "$1"\n' > .git/hooks/commit-msg
+			  chmod +x .git/hooks/commit-msg
+		`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+
+			var msg []byte
+			var err error
+			if args[0] == "-" {
+				msg, err = io.ReadAll(os.Stdin)
+			} else {
+				msg, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			// rules fall back to their defaults without a config
+			err = s.LoadConfig()
+			if err != nil && !errors.Is(err, gopushSvc.ErrFileNotFound) {
+				return err
+			}
+			return s.LintCommitMsg(string(msg))
+		},
+	}
+	return lintCmd
+}
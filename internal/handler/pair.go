@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+const clearFlag = "clear"
+
+func Pair(s servicer) *cobra.Command {
+	clear := false
+	pairCmd := &cobra.Command{
+		Use:   "pair [\"Name <email>\"...]",
+		Short: "credits co-authors on every commit of a pairing session",
+		Long: heredoc.Doc(`
+			pair saves co-authors that are added as Co-authored-by trailers to every
+			commit made by gopush run, until the session is cleared with --clear.
+			Without arguments it prints the current session.
+
+			  gopush pair "Jane Doe <jane@example.com>"
+		`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+			return s.Pair(args, clear)
+		},
+	}
+	pairCmd.Flags().BoolVar(&clear, clearFlag, false, "end the pairing session")
+	return pairCmd
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/gopushSvc"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	continueFlag = "continue"
+	abortFlag    = "abort"
+)
+
+func Rebase(s servicer) *cobra.Command {
+	cont := false
+	abort := false
+	rebaseCmd := &cobra.Command{
+		Use:   "rebase",
+		Short: "continues or aborts a rebase stopped on conflicts",
+		Long: heredoc.Doc(`
+			When gopush run --rebase stops on conflicts, fix the listed files and
+			run "gopush rebase --continue" to commit them and replay the remaining
+			commits, or "gopush rebase --abort" to put the branch back as it was.
+			Stashed local changes are restored either way.
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+			err := s.LoadProject()
+			if err != nil {
+				return err
+			}
+			err = s.LoadConfig()
+			if err != nil && !errors.Is(err, gopushSvc.ErrFileNotFound) {
+				return err
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if abort {
+				return s.RebaseAbort()
+			}
+			return s.RebaseContinue()
+		},
+	}
+	rebaseCmd.Flags().BoolVar(&cont, continueFlag, false, "commit the resolved files and replay the remaining commits")
+	rebaseCmd.Flags().BoolVar(&abort, abortFlag, false, "put the branch back where it was before the rebase")
+	rebaseCmd.MarkFlagsMutuallyExclusive(continueFlag, abortFlag)
+	rebaseCmd.MarkFlagsOneRequired(continueFlag, abortFlag)
+	return rebaseCmd
+}
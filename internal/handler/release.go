@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/seriouspoop/gopush/gopushSvc"
+	"github.com/seriouspoop/gopush/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	bumpFlag      = "bump"
+	dryRunFlag    = "dry-run"
+	changelogFlag = "changelog"
+)
+
+func Release(s servicer) *cobra.Command {
+	var bump string
+	dryRun, changelog := false, false
+	releaseCmd := &cobra.Command{
+		Use:   "release",
+		Short: "tags the next semantic version and pushes it",
+		Long: heredoc.Doc(`
+			release reads the commits since the last vX.Y.Z tag and computes the next
+			version from their conventional commit types: a breaking change bumps the
+			major version, feat the minor one and fix or perf the patch. HEAD is then
+			tagged with an annotated tag, signed when commit signing is enabled, and
+			the tag is pushed along with the branch.
+
+			With --changelog the changelog is updated for the new version and
+			committed before tagging.
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			cmd.SetErrPrefix(fmt.Sprintf("%s Error:", utils.ErrorSymbol()))
+			err := s.LoadProject()
+			if err != nil {
+				return err
+			}
+			err = s.LoadConfig()
+			if err != nil && !errors.Is(err, gopushSvc.ErrFileNotFound) {
+				return err
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.Release(cmd.Context(), bump, dryRun, changelog)
+		},
+	}
+	releaseCmd.Flags().StringVar(&bump, bumpFlag, "", "force the bump, major, minor or patch")
+	releaseCmd.Flags().BoolVar(&dryRun, dryRunFlag, false, "show the next version and its commits without tagging")
+	releaseCmd.Flags().BoolVar(&changelog, changelogFlag, false, "commit the updated changelog before tagging")
+	return releaseCmd
+}
@@ -14,24 +14,69 @@ import (
 const (
 	newBranchFlag   = "new-branch"
 	setUpstreamFlag = "set-upstream"
+	streamFlag      = "stream"
+	snapshotFlag    = "snapshot"
+	allFlag         = "all"
+	patchFlag       = "patch"
+	messageFlag     = "message"
+	coAuthorFlag    = "co-author"
+	amendFlag       = "amend"
+	fixupFlag       = "fixup"
+	forceLeaseFlag  = "force-with-lease"
+	rebaseFlag      = "rebase"
+	skipTestsFlag   = "skip-tests"
 )
 
 func Run(s servicer) *cobra.Command {
 	var newBranch string
 	setUpstreamBranch := false
+	stream := false
+	snapshot := false
+	all := false
+	patch := false
+	var message string
+	var coAuthors []string
+	amend := false
+	var fixup string
+	forceWithLease := false
+	rebase := false
+	skipTests := false
 
 	runCmd := &cobra.Command{
 		Use:   "run",
 		Short: "runs tests and push on remote.",
 		Long: heredoc.Doc(`
 
-			run command generates the mocks in current directory with go generate ./...
-			then runs tests with go test ./...
-			If all tests are passed, then modified files are staged following 
-			push on the current repo's remote counterpart.
+			run command lets you pick the files to commit (--all stages everything,
+			--patch picks hunks) and commits them. The remote changes of the branch
+			and of the base branch are then brought in, go generate runs when enabled
+			in config and tests run with go test ./... on the integrated code.
+			If all tests are passed, the branch is pushed to the current repo's
+			remote counterpart.
 
-			[NOTE] Before pushing changes, changes from the remote main are pulled and are 
-			attempted to merge to current branch. 
+			With --snapshot, generate and tests run on a temporary checkout of the
+			committed and integrated code, so the result applies to exactly what gets
+			pushed, leaving out unstaged and untracked files.
+
+			When generate or tests fail, the commits of the run are undone: the branch
+			goes back to where it was, the changes of the run are left uncommitted and
+			staged as before the run, and the changes pulled in are dropped.
+
+			--amend folds the changes into the last commit and --fixup <sha> into an
+			earlier one, squashing the fixup commit before the push. Commits already
+			on the remote are only rewritten with --force-with-lease, which replaces
+			the remote branch as long as it did not move since the last fetch.
+			Pushes are never forced otherwise, and branches listed in push.protected
+			are never forced at all.
+
+			Branch policies in config can refuse direct pushes to a branch, in which
+			case a feature branch is offered instead, forbid force pushes, require a
+			linear history or require the tests to run.
+
+			[NOTE] The base branch is pull.base in config, or the default branch of the
+			remote, and changes from it are merged into the current branch.
+			With --rebase, or pull.rebase in config, local commits are replayed on top
+			of them instead, uncommitted changes are stashed meanwhile.
 		`),
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) > 0 {
@@ -63,6 +108,11 @@ func Run(s servicer) *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := s.BeginRun(cmd.Context())
+			if err != nil {
+				return err
+			}
+
 			branch := model.Branch(newBranch)
 			if branch.Valid() {
 				branchExist := false
@@ -78,28 +128,47 @@ func Run(s servicer) *cobra.Command {
 				}
 			}
 
-			// Generate Tests and Run
-			utils.Logger(utils.LOG_INFO, "Generating and Running tests...")
-			testValid, err := s.CheckTestsAndRun()
+			// apply the branch policy before anything is committed
+			err = s.CheckPolicy(cmd.Context(), skipTests)
 			if err != nil {
 				return err
 			}
-			if testValid {
-				utils.Logger(utils.LOG_SUCCESS, "tests passed")
-			} else {
-				utils.Logger(utils.LOG_SUCCESS, "no tests found")
+
+			// refuse to rewrite pushed commits before anything is staged
+			rewrite := fixup
+			if amend {
+				rewrite = "HEAD"
+			}
+			if rewrite != "" {
+				err := s.CheckRewrite(cmd.Context(), rewrite, forceWithLease)
+				if err != nil {
+					return err
+				}
 			}
-			utils.Logger(utils.LOG_INFO, "Staging changes...")
 
 			// stage changes
-			err = s.StageChanges()
+			utils.Logger(utils.LOG_INFO, "Staging changes...")
+			err = s.StageChanges(cmd.Context(), all, patch)
+			if err != nil {
+				return err
+			}
+
+			// commit staged changes
+			err = s.Commit(cmd.Context(), message, coAuthors, amend, fixup)
 			if err != nil {
 				return err
 			}
 
 			// Pull changes
 			utils.Logger(utils.LOG_INFO, "Pulling remote changes...")
-			err = s.Pull(false)
+			err = s.Pull(cmd.Context(), false, rebase, forceWithLease)
+			for errors.Is(err, gopushSvc.ErrMergeConflict) || errors.Is(err, gopushSvc.ErrRebaseConflict) {
+				err = s.ResolveConflicts(cmd.Context(), err)
+				if err == nil {
+					// the base branch may still have to come in
+					err = s.Pull(cmd.Context(), false, rebase, forceWithLease)
+				}
+			}
 			if err != nil {
 				if errors.Is(err, gopushSvc.ErrAuthNotFound) {
 					fmt.Println(heredoc.Doc(`
@@ -110,9 +179,31 @@ func Run(s servicer) *cobra.Command {
 				return err
 			}
 
+			// Generate Tests and Run
+			if skipTests {
+				utils.Logger(utils.LOG_WARNING, "tests skipped")
+			} else {
+				utils.Logger(utils.LOG_INFO, "Running tests...")
+				testValid, err := s.CheckTestsAndRun(cmd.Context(), stream, snapshot)
+				if err != nil {
+					// leave nothing committed that failed the tests
+					return errors.Join(err, s.UndoRun(err))
+				}
+				if testValid {
+					utils.Logger(utils.LOG_SUCCESS, "tests passed")
+				} else {
+					utils.Logger(utils.LOG_SUCCESS, "no tests found")
+				}
+			}
+
+			err = s.TrackingStatus(cmd.Context())
+			if err != nil {
+				return err
+			}
+
 			// Push changes
 			utils.Logger(utils.LOG_INFO, "Pushing changes...")
-			err = s.Push(setUpstreamBranch)
+			err = s.Push(cmd.Context(), setUpstreamBranch, forceWithLease)
 			if err != nil {
 				if errors.Is(err, gopushSvc.ErrAuthNotFound) {
 					fmt.Println(heredoc.Doc(`
@@ -125,8 +216,22 @@ func Run(s servicer) *cobra.Command {
 			return nil
 		},
 	}
-	runCmd.PersistentFlags().StringVarP(&newBranch, newBranchFlag, "b", "", "create new branch and set-upstream")
-	runCmd.PersistentFlags().BoolVarP(&setUpstreamBranch, setUpstreamFlag, "u", false, "upstreams the given branch to remote")
+	runCmd.PersistentFlags().StringVarP(&newBranch, newBranchFlag, "b", "", "switch to a branch, creating it with the branch template when missing")
+	runCmd.PersistentFlags().BoolVarP(&setUpstreamBranch, setUpstreamFlag, "u", false, "make the current branch track its counterpart on the remote")
+	runCmd.PersistentFlags().BoolVar(&stream, streamFlag, false, "show generate and test output live")
+	runCmd.PersistentFlags().BoolVar(&snapshot, snapshotFlag, false, "run generate and tests on a checkout of what gets pushed")
+	runCmd.PersistentFlags().BoolVarP(&all, allFlag, "a", false, "stage every changed file without asking")
+	runCmd.PersistentFlags().BoolVarP(&patch, patchFlag, "p", false, "pick the hunks to stage within each selected file")
+	runCmd.PersistentFlags().StringVarP(&message, messageFlag, "m", "", "commit message, skips the prompt")
+	runCmd.PersistentFlags().StringArrayVar(&coAuthors, coAuthorFlag, nil, "credit a co-author, \"Name <email>\", repeatable")
+	runCmd.PersistentFlags().BoolVar(&amend, amendFlag, false, "fold the changes into the last commit")
+	runCmd.PersistentFlags().StringVar(&fixup, fixupFlag, "", "fold the changes into the given commit before pushing")
+	runCmd.PersistentFlags().BoolVar(&forceWithLease, forceLeaseFlag, false, "rewrite commits already on the remote and replace the remote branch if it did not move since the last fetch")
+	runCmd.PersistentFlags().BoolVar(&rebase, rebaseFlag, false, "replay local commits onto the remote changes instead of merging")
+	runCmd.PersistentFlags().BoolVar(&skipTests, skipTestsFlag, false, "push without running generate and tests, unless the branch policy requires them")
+	runCmd.MarkFlagsMutuallyExclusive(allFlag, patchFlag)
+	runCmd.MarkFlagsMutuallyExclusive(amendFlag, fixupFlag)
+	runCmd.MarkFlagsMutuallyExclusive(fixupFlag, messageFlag)
 	runCmd.MarkFlagsMutuallyExclusive(newBranchFlag, setUpstreamFlag)
 	return runCmd
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/seriouspoop/gopush/internal/handler"
 	"github.com/seriouspoop/gopush/repo/git"
 	"github.com/seriouspoop/gopush/repo/script"
+	"github.com/seriouspoop/gopush/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -17,18 +18,35 @@ type Root struct {
 
 func NewRoot() (*Root, error) {
 	gitHelper, err := git.New(&git.Errors{
-		RemoteNotFound:       gopushSvc.ErrRemoteNotFound,
-		RemoteNotLoaded:      gopushSvc.ErrRemoteNotLoaded,
-		RemoteAlreadyExists:  gopushSvc.ErrRemoteAlreadyExists,
-		RepoAlreadyExists:    gopushSvc.ErrRepoAlreadyExists,
-		RepoNotFound:         gopushSvc.ErrRepoNotFound,
-		PullFailed:           gopushSvc.ErrPullFailed,
-		AuthNotFound:         gopushSvc.ErrAuthNotFound,
-		InvalidAuthMethod:    gopushSvc.ErrInvalidAuthMethod,
-		InvalidPassphrase:    gopushSvc.ErrInvalidPassphrase,
-		KeyNotSupported:      gopushSvc.ErrKeyNotSupported,
-		AlreadyUpToDate:      gopushSvc.ErrAlreadyUpToDate,
-		RemoteBranchNotFound: gopushSvc.ErrRemoteBranchNotFound,
+		RemoteNotFound:         gopushSvc.ErrRemoteNotFound,
+		RemoteNotLoaded:        gopushSvc.ErrRemoteNotLoaded,
+		RemoteAlreadyExists:    gopushSvc.ErrRemoteAlreadyExists,
+		RepoAlreadyExists:      gopushSvc.ErrRepoAlreadyExists,
+		RepoNotFound:           gopushSvc.ErrRepoNotFound,
+		PullFailed:             gopushSvc.ErrPullFailed,
+		AuthNotFound:           gopushSvc.ErrAuthNotFound,
+		InvalidAuthMethod:      gopushSvc.ErrInvalidAuthMethod,
+		InvalidPassphrase:      gopushSvc.ErrInvalidPassphrase,
+		KeyNotSupported:        gopushSvc.ErrKeyNotSupported,
+		AlreadyUpToDate:        gopushSvc.ErrAlreadyUpToDate,
+		MergeFailed:            gopushSvc.ErrMergeFailed,
+		RemoteBranchNotFound:   gopushSvc.ErrRemoteBranchNotFound,
+		SignKeyNotFound:        gopushSvc.ErrSignKeyNotFound,
+		SignFormatNotSupported: gopushSvc.ErrSignFormat,
+		CommitNotFound:         gopushSvc.ErrCommitNotFound,
+		AutosquashFailed:       gopushSvc.ErrAutosquashFailed,
+		DetachedHead:           gopushSvc.ErrDetachedHead,
+		RebaseConflict:         gopushSvc.ErrRebaseConflict,
+		RebaseInProgress:       gopushSvc.ErrRebaseInProgress,
+		NoRebase:               gopushSvc.ErrNoRebase,
+		ConflictsRemain:        gopushSvc.ErrConflictsRemain,
+		MergeConflict:          gopushSvc.ErrMergeConflict,
+		MergeInProgress:        gopushSvc.ErrMergeInProgress,
+		UnrelatedHistories:     gopushSvc.ErrUnrelatedHistories,
+		NoMerge:                gopushSvc.ErrNoMerge,
+		PushRejected:           gopushSvc.ErrPushRejected,
+		StaleLease:             gopushSvc.ErrStaleLease,
+		TagExists:              gopushSvc.ErrTagExists,
 	})
 	if err != nil {
 		return nil, err
@@ -48,6 +66,7 @@ func NewRoot() (*Root, error) {
 }
 
 func (r *Root) RootCMD() *cobra.Command {
+	var output string
 	rootCMD := &cobra.Command{
 		Use:     "gopush",
 		Version: "1.1.5",
@@ -61,10 +80,27 @@ func (r *Root) RootCMD() *cobra.Command {
 			 ╚═════╝   ╚═════╝  ╚═╝       ╚═════╝  ╚══════╝ ╚═╝  ╚═╝
 			`),
 		Long: "",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := utils.ParseOutputMode(output)
+			if err != nil {
+				return err
+			}
+			utils.SetOutputMode(mode)
+			return nil
+		},
 	}
+	rootCMD.PersistentFlags().StringVarP(&output, "output", "o", "text", "output mode: text, quiet or json")
 
 	rootCMD.AddCommand(handler.Run(r.s))
 	rootCMD.AddCommand(handler.Init(r.s))
+	rootCMD.AddCommand(handler.LintCommit(r.s))
+	rootCMD.AddCommand(handler.Pair(r.s))
+	rootCMD.AddCommand(handler.Branch(r.s))
+	rootCMD.AddCommand(handler.Rebase(r.s))
+	rootCMD.AddCommand(handler.Continue(r.s))
+	rootCMD.AddCommand(handler.Abort(r.s))
+	rootCMD.AddCommand(handler.Release(r.s))
+	rootCMD.AddCommand(handler.Changelog(r.s))
 
 	return rootCMD
 }
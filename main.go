@@ -1,17 +1,31 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/seriouspoop/gopush/internal"
 )
 
 func main() {
+	// first interrupt cancels the running stage, a second one kills gopush
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	root, err := internal.NewRoot()
 	if err != nil {
 		return
 	}
 
-	err = root.RootCMD().Execute()
+	err = root.RootCMD().ExecuteContext(ctx)
 	if err != nil {
-		return
+		stop()
+		os.Exit(1)
 	}
 }
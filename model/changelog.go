@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// BreakingSection is the changelog section key of breaking changes, whatever
+// their commit type.
+const BreakingSection = "breaking"
+
+// ChangelogEntry is a conventional commit as it appears in the changelog.
+type ChangelogEntry struct {
+	Hash    string
+	Type    string
+	Scope   string
+	Subject string
+	// Breaking describes the breaking change, empty when the commit is not breaking.
+	Breaking string
+	Refs     []string
+}
+
+func (e *ChangelogEntry) ShortHash() string {
+	if len(e.Hash) < 7 {
+		return e.Hash
+	}
+	return e.Hash[:7]
+}
+
+// ChangelogSection groups the entries of a release under a title, Type being
+// the commit type it collects, the first one when several share the title, or
+// BreakingSection.
+type ChangelogSection struct {
+	Type    string
+	Title   string
+	Entries []*ChangelogEntry
+}
+
+// ChangelogRelease holds the sections of a release. Tag is empty for the
+// commits not released yet, Previous for the first release.
+type ChangelogRelease struct {
+	Tag      string
+	Version  string
+	Previous string
+	Date     time.Time
+	Sections []*ChangelogSection
+}
+
+func (r *ChangelogRelease) Unreleased() bool {
+	return r.Tag == ""
+}
+
+// Changelog is what changelog templates render, the releases newest first.
+type Changelog struct {
+	Releases []*ChangelogRelease
+}
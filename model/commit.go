@@ -0,0 +1,120 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	commitHeader  = regexp.MustCompile(`^(\w+)(?:\(([^()]*)\))?(!)?: (.*)$`)
+	commitTrailer = regexp.MustCompile(`^(BREAKING[ -]CHANGE|[A-Za-z][\w-]*)(?:: | #)(.*)$`)
+)
+
+// Footer is a git trailer style line at the end of a commit message, e.g.
+// "Refs: PROJ-12" or "Reviewed-by: Jane <jane@example.com>".
+type Footer struct {
+	Token string
+	Value string
+}
+
+// CommitMessage is a Conventional Commits message split into its parts.
+type CommitMessage struct {
+	Type    string
+	Scope   string
+	Subject string
+	Body    string
+	// Breaking describes the breaking change, empty when the commit is not breaking.
+	Breaking string
+	Refs     []string
+	// Footers holds every other footer, Refs and BREAKING CHANGE excluded.
+	Footers []Footer
+	// Ticket is the issue key found in the branch name.
+	Ticket string
+}
+
+func (c *CommitMessage) IsBreaking() bool {
+	return c.Breaking != ""
+}
+
+// Header returns the first line, "type(scope)!: subject".
+func (c *CommitMessage) Header() string {
+	var b strings.Builder
+	b.WriteString(c.Type)
+	if c.Scope != "" {
+		fmt.Fprintf(&b, "(%s)", c.Scope)
+	}
+	if c.IsBreaking() {
+		b.WriteString("!")
+	}
+	fmt.Fprintf(&b, ": %s", c.Subject)
+	return b.String()
+}
+
+// IsTrailer reports whether line is a footer line.
+func IsTrailer(line string) bool {
+	return commitTrailer.MatchString(line)
+}
+
+// ParseCommitMessage splits text into its conventional parts, ok is false when
+// the header does not follow the Conventional Commits format.
+func ParseCommitMessage(text string) (*CommitMessage, bool) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	m := commitHeader.FindStringSubmatch(lines[0])
+	if m == nil {
+		return &CommitMessage{Subject: lines[0]}, false
+	}
+	msg := &CommitMessage{
+		Type:    m[1],
+		Scope:   m[2],
+		Subject: m[4],
+	}
+
+	paragraphs := [][]string{}
+	current := []string{}
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, current)
+				current = []string{}
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, current)
+	}
+
+	if n := len(paragraphs); n > 0 && IsTrailer(paragraphs[n-1][0]) {
+		for _, line := range paragraphs[n-1] {
+			f := commitTrailer.FindStringSubmatch(line)
+			if f == nil {
+				// continuation of the previous footer value
+				if len(msg.Footers) > 0 {
+					msg.Footers[len(msg.Footers)-1].Value += "\n" + line
+				}
+				continue
+			}
+			switch f[1] {
+			case "BREAKING CHANGE", "BREAKING-CHANGE":
+				msg.Breaking = f[2]
+			case "Refs":
+				msg.Refs = append(msg.Refs, f[2])
+			default:
+				msg.Footers = append(msg.Footers, Footer{Token: f[1], Value: f[2]})
+			}
+		}
+		paragraphs = paragraphs[:n-1]
+	}
+
+	body := make([]string, len(paragraphs))
+	for i, p := range paragraphs {
+		body[i] = strings.Join(p, "\n")
+	}
+	msg.Body = strings.Join(body, "\n\n")
+	if m[3] == "!" && msg.Breaking == "" {
+		msg.Breaking = msg.Subject
+	}
+	return msg, true
+}
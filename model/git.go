@@ -1,6 +1,7 @@
 package model
 
 import (
+	"net/url"
 	"strings"
 )
 
@@ -31,6 +32,7 @@ const (
 	ProviderGITHUB
 	ProviderBITBUCKET
 	ProviderGITLAB
+	ProviderGITEA
 )
 
 func (p Provider) String() string {
@@ -40,18 +42,20 @@ func (p Provider) String() string {
 		return "BitBucket"
 	} else if p == ProviderGITLAB {
 		return "GitLab"
+	} else if p == ProviderGITEA {
+		return "Gitea"
 	}
 	return ""
 }
 
-func (p Provider) HostURL() string {
-	providerToHostMap := map[Provider]string{
-		ProviderGITHUB:    "github.com",
-		ProviderBITBUCKET: "bitbucket.org",
-		ProviderGITLAB:    "gitlab.com",
+// ParseProvider reads a provider name as String writes it, in any case.
+func ParseProvider(name string) Provider {
+	for _, p := range []Provider{ProviderGITHUB, ProviderBITBUCKET, ProviderGITLAB, ProviderGITEA} {
+		if strings.EqualFold(p.String(), name) {
+			return p
+		}
 	}
-
-	return providerToHostMap[p]
+	return ProviderUNKOWN
 }
 
 type AuthMode int
@@ -67,16 +71,43 @@ type Remote struct {
 	Url  string
 }
 
+// Host is the host name of the remote url, for http, ssh:// and scp-like
+// [user@]host:path urls alike.
+func (r *Remote) Host() string {
+	if u, err := url.Parse(r.Url); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	host, _, found := strings.Cut(r.Url, ":")
+	if !found {
+		return ""
+	}
+	if _, name, ok := strings.Cut(host, "@"); ok {
+		host = name
+	}
+	return host
+}
+
+// Provider guesses the provider from the host of the remote: the hosted
+// services by their host, self-hosted ones when the host name holds the
+// provider name, like gitlab.example.com.
 func (r *Remote) Provider() Provider {
-	if strings.Contains(r.Url, "github") {
-		return ProviderGITHUB
-	} else if strings.Contains(r.Url, "bitbucket") {
-		return ProviderBITBUCKET
-	} else if strings.Contains(r.Url, "gitlab") {
-		return ProviderGITLAB
-	} else {
-		return ProviderUNKOWN
+	hostToProvider := map[string]Provider{
+		"github.com":    ProviderGITHUB,
+		"bitbucket.org": ProviderBITBUCKET,
+		"gitlab.com":    ProviderGITLAB,
+		"gitea.com":     ProviderGITEA,
+		"codeberg.org":  ProviderGITEA,
+	}
+	host := strings.ToLower(r.Host())
+	if p, ok := hostToProvider[host]; ok {
+		return p
 	}
+	for _, p := range []Provider{ProviderGITHUB, ProviderBITBUCKET, ProviderGITLAB, ProviderGITEA} {
+		if strings.Contains(host, strings.ToLower(p.String())) {
+			return p
+		}
+	}
+	return ProviderUNKOWN
 }
 
 func (r *Remote) AuthMode() AuthMode {
@@ -88,3 +119,33 @@ func (r *Remote) AuthMode() AuthMode {
 		return AuthUNKNOWN
 	}
 }
+
+// Commit is a commit of the repository history.
+type Commit struct {
+	Hash    string
+	Message string
+}
+
+// Subject returns the first line of the message.
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+func (c *Commit) ShortHash() string {
+	if len(c.Hash) < 7 {
+		return c.Hash
+	}
+	return c.Hash[:7]
+}
+
+// Upstream is the remote branch a local branch tracks, as set in
+// branch.<name>.remote and branch.<name>.merge.
+type Upstream struct {
+	Remote string
+	Branch Branch
+}
+
+func (u *Upstream) String() string {
+	return u.Remote + "/" + u.Branch.String()
+}
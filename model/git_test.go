@@ -0,0 +1,29 @@
+package model
+
+import "testing"
+
+func TestRemoteHost(t *testing.T) {
+	tests := []struct {
+		url          string
+		wantHost     string
+		wantProvider Provider
+	}{
+		{"https://github.com/seriouspoop/gopush.git", "github.com", ProviderGITHUB},
+		{"git@github.com:seriouspoop/gopush.git", "github.com", ProviderGITHUB},
+		{"ssh://git@gitlab.com:2222/group/repo.git", "gitlab.com", ProviderGITLAB},
+		{"https://user@bitbucket.org/team/repo.git", "bitbucket.org", ProviderBITBUCKET},
+		{"git@codeberg.org:me/repo.git", "codeberg.org", ProviderGITEA},
+		{"https://gitea.example.com/me/repo.git", "gitea.example.com", ProviderGITEA},
+		{"git@git.example.com:me/github-tools.git", "git.example.com", ProviderUNKOWN},
+		{"/srv/repo.git", "", ProviderUNKOWN},
+	}
+	for _, tt := range tests {
+		r := &Remote{Name: "origin", Url: tt.url}
+		if got := r.Host(); got != tt.wantHost {
+			t.Errorf("Host() of %q = %q, want %q", tt.url, got, tt.wantHost)
+		}
+		if got := r.Provider(); got != tt.wantProvider {
+			t.Errorf("Provider() of %q = %v, want %v", tt.url, got, tt.wantProvider)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var identityFormat = regexp.MustCompile(`^\s*([^<>]+?)\s*<([^<>\s]+@[^<>\s]+)>\s*$`)
+
+// Identity is the name and email recorded as the author or committer of a
+// commit.
+type Identity struct {
+	Name  string
+	Email string
+}
+
+func (i *Identity) Valid() bool {
+	return i != nil && i.Name != "" && i.Email != ""
+}
+
+// String returns the "Name <email>" form used in trailers.
+func (i *Identity) String() string {
+	return fmt.Sprintf("%s <%s>", i.Name, i.Email)
+}
+
+// ParseIdentity reads an identity in the "Name <email>" form.
+func ParseIdentity(text string) (*Identity, bool) {
+	m := identityFormat.FindStringSubmatch(text)
+	if m == nil {
+		return nil, false
+	}
+	return &Identity{Name: strings.TrimSpace(m[1]), Email: m[2]}, true
+}
@@ -0,0 +1,45 @@
+package model
+
+import "fmt"
+
+// ConflictKind says why a file could not be merged.
+type ConflictKind string
+
+const (
+	// ConflictContent is a text file both sides changed on the same lines.
+	ConflictContent ConflictKind = "content"
+	// ConflictAdded is a file both sides added with different content.
+	ConflictAdded ConflictKind = "added by both"
+	// ConflictDeleted is a file one side changed and the other deleted.
+	ConflictDeleted ConflictKind = "deleted by one side"
+	// ConflictBinary is a binary file, symlink or submodule both sides changed.
+	ConflictBinary ConflictKind = "binary"
+)
+
+// Conflict is a file left for the user to resolve.
+type Conflict struct {
+	Path string       `json:"path"`
+	Kind ConflictKind `json:"kind"`
+}
+
+func (c *Conflict) String() string {
+	return fmt.Sprintf("%s (%s)", c.Path, c.Kind)
+}
+
+// Merge is the outcome of merging an upstream into the current branch.
+type Merge struct {
+	Head      string
+	MergeHead string
+	// FastForward is set when the branch only had to move to MergeHead.
+	FastForward bool
+	Conflicts   []*Conflict
+}
+
+// Checkpoint is where the branch was when a run started, kept while the run
+// is stopped on conflicts so it can be aborted.
+type Checkpoint struct {
+	Branch Branch `json:"branch"`
+	Head   string `json:"head"`
+	// Stopped is HEAD right before the pull that stopped the run.
+	Stopped string `json:"stopped"`
+}
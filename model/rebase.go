@@ -0,0 +1,24 @@
+package model
+
+// Rebase is the progress of replaying local commits onto their upstream. It
+// is kept in the git directory while the rebase is stopped on conflicts.
+type Rebase struct {
+	Branch Branch `json:"branch"`
+	// Onto is the upstream commit the local commits are replayed onto.
+	Onto     string `json:"onto"`
+	OrigHead string `json:"orig_head"`
+	// Todo lists the commits left to replay, oldest first. When stopped, the
+	// first one is the commit in conflict.
+	Todo []string `json:"todo"`
+	// Tip is the last commit written by the rebase.
+	Tip string `json:"tip"`
+	// Replayed counts the commits written so far.
+	Replayed int `json:"replayed"`
+	// Autostash is the commit holding the changes stashed before the rebase.
+	Autostash string      `json:"autostash,omitempty"`
+	Conflicts []*Conflict `json:"conflicts,omitempty"`
+}
+
+func (r *Rebase) Stopped() bool {
+	return len(r.Conflicts) > 0
+}
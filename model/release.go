@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var semverTag = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)$`)
+
+// Version is a semantic version, tagged as v<major>.<minor>.<patch>.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion reads a release tag, with or without the leading v. Pre-release
+// and build tags are not releases and ok is false for them.
+func ParseVersion(tag string) (v Version, ok bool) {
+	m := semverTag.FindStringSubmatch(tag)
+	if m == nil {
+		return Version{}, false
+	}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, true
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v Version) Tag() string {
+	return "v" + v.String()
+}
+
+// Less orders versions by precedence.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// Bump is the part of the version a release increments.
+type Bump int
+
+const (
+	BumpNone Bump = iota
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	bumpToName := map[Bump]string{
+		BumpPatch: "patch",
+		BumpMinor: "minor",
+		BumpMajor: "major",
+	}
+	return bumpToName[b]
+}
+
+// ParseBump reads "major", "minor" or "patch", anything else is BumpNone.
+func ParseBump(name string) Bump {
+	for _, b := range []Bump{BumpPatch, BumpMinor, BumpMajor} {
+		if b.String() == name {
+			return b
+		}
+	}
+	return BumpNone
+}
+
+// BumpFor is the bump a commit calls for: major for breaking changes, minor
+// for features and patch for fixes. Other types do not make a release.
+func BumpFor(msg *CommitMessage) Bump {
+	switch {
+	case msg.IsBreaking():
+		return BumpMajor
+	case msg.Type == "feat":
+		return BumpMinor
+	case msg.Type == "fix" || msg.Type == "perf":
+		return BumpPatch
+	}
+	return BumpNone
+}
+
+// Next returns the version after v for bump.
+func (v Version) Next(bump Bump) Version {
+	switch bump {
+	case BumpMajor:
+		return Version{Major: v.Major + 1}
+	case BumpMinor:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	case BumpPatch:
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	return v
+}
+
+// Tag is a tag of the repository history, Commit being the commit it points
+// to once peeled.
+type Tag struct {
+	Name   string
+	Commit string
+	// Date is the tagger date of annotated tags, the commit date otherwise.
+	Date time.Time
+}
@@ -0,0 +1,87 @@
+package model
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Version
+		ok   bool
+	}{
+		{"v1.2.3", Version{1, 2, 3}, true},
+		{"1.2.3", Version{1, 2, 3}, true},
+		{"v0.0.0", Version{}, true},
+		{"v1.2", Version{}, false},
+		{"v01.2.3", Version{}, false},
+		{"v1.2.3-rc.1", Version{}, false},
+		{"v1.2.3+build", Version{}, false},
+		{"release-1", Version{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseVersion(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBumpFor(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want Bump
+	}{
+		{"feat: add login", BumpMinor},
+		{"fix: crash on start", BumpPatch},
+		{"perf: faster pull", BumpPatch},
+		{"feat!: drop the old flag", BumpMajor},
+		{"fix(api)!: rename field", BumpMajor},
+		{"refactor: split files\n\nBREAKING CHANGE: the config moved", BumpMajor},
+		{"docs: typo", BumpNone},
+		{"chore: bump deps", BumpNone},
+	}
+	for _, tt := range tests {
+		msg, ok := ParseCommitMessage(tt.msg)
+		if !ok {
+			t.Fatalf("ParseCommitMessage(%q) failed", tt.msg)
+		}
+		if got := BumpFor(msg); got != tt.want {
+			t.Errorf("BumpFor(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestVersionNext(t *testing.T) {
+	v := Version{Major: 1, Minor: 4, Patch: 2}
+	tests := []struct {
+		bump Bump
+		want Version
+	}{
+		{BumpMajor, Version{2, 0, 0}},
+		{BumpMinor, Version{1, 5, 0}},
+		{BumpPatch, Version{1, 4, 3}},
+		{BumpNone, Version{1, 4, 2}},
+	}
+	for _, tt := range tests {
+		if got := v.Next(tt.bump); got != tt.want {
+			t.Errorf("%v.Next(%v) = %v, want %v", v, tt.bump, got, tt.want)
+		}
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b Version
+		want bool
+	}{
+		{Version{1, 0, 0}, Version{2, 0, 0}, true},
+		{Version{1, 2, 0}, Version{1, 10, 0}, true},
+		{Version{1, 2, 3}, Version{1, 2, 4}, true},
+		{Version{1, 2, 3}, Version{1, 2, 3}, false},
+		{Version{2, 0, 0}, Version{1, 9, 9}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Less(tt.b); got != tt.want {
+			t.Errorf("%v.Less(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package model
+
+// SignFormat is the kind of key commits are signed with, named as in git's
+// gpg.format setting.
+type SignFormat string
+
+const (
+	SignOpenPGP SignFormat = "openpgp"
+	SignSSH     SignFormat = "ssh"
+)
+
+func (f SignFormat) String() string {
+	return string(f)
+}
+
+// Signing describes the key used to sign commits.
+type Signing struct {
+	Format SignFormat
+	// Key is the armored keyring file for openpgp or the private key file for ssh.
+	Key string
+	// KeyID selects the openpgp key in the keyring, the first key able to
+	// sign is used when empty.
+	KeyID string
+}
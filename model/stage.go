@@ -0,0 +1,17 @@
+package model
+
+// Stage names a step of the gopush pipeline that may run an external command
+// or talk to a remote.
+type Stage string
+
+const (
+	StageGenerate Stage = "generate"
+	StageTest     Stage = "test"
+	StagePull     Stage = "pull"
+	StagePush     Stage = "push"
+	StageKeygen   Stage = "keygen"
+)
+
+func (s Stage) String() string {
+	return string(s)
+}
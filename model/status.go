@@ -0,0 +1,85 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StatusCode is the short status code git prints for a file, e.g. 'M' or '?'.
+type StatusCode byte
+
+const (
+	StatusUnmodified StatusCode = ' '
+	StatusUntracked  StatusCode = '?'
+	StatusModified   StatusCode = 'M'
+	StatusAdded      StatusCode = 'A'
+	StatusDeleted    StatusCode = 'D'
+	StatusRenamed    StatusCode = 'R'
+	StatusCopied     StatusCode = 'C'
+	StatusUnmerged   StatusCode = 'U'
+)
+
+// FileChange is a file that differs between HEAD, the index and the worktree.
+type FileChange struct {
+	Path     string
+	Staging  StatusCode
+	Worktree StatusCode
+}
+
+func (f *FileChange) Tracked() bool {
+	return f.Worktree != StatusUntracked
+}
+
+// Staged reports whether the index holds a change of the file.
+func (f *FileChange) Staged() bool {
+	return f.Staging != StatusUnmodified && f.Staging != StatusUntracked
+}
+
+func (f *FileChange) String() string {
+	return fmt.Sprintf("%c%c %s", f.Staging, f.Worktree, f.Path)
+}
+
+// Hunk is a run of changed lines between the staged and the worktree version
+// of a file, with a few unchanged lines around it for context. Whole is set
+// when the file can only be staged as a whole, e.g. new, deleted or binary files.
+type Hunk struct {
+	Path    string
+	Before  []string
+	Removed []string
+	Added   []string
+	After   []string
+	Whole   bool
+}
+
+func (h *Hunk) String() string {
+	if h.Whole {
+		return h.Path
+	}
+	var b strings.Builder
+	for _, l := range h.Before {
+		fmt.Fprintf(&b, "  %s\n", strings.TrimSuffix(l, "\n"))
+	}
+	for _, l := range h.Removed {
+		fmt.Fprintf(&b, "- %s\n", strings.TrimSuffix(l, "\n"))
+	}
+	for _, l := range h.Added {
+		fmt.Fprintf(&b, "+ %s\n", strings.TrimSuffix(l, "\n"))
+	}
+	for _, l := range h.After {
+		fmt.Fprintf(&b, "  %s\n", strings.TrimSuffix(l, "\n"))
+	}
+	return b.String()
+}
+
+// AddedLine is a line introduced by the staged changes, Number is its 1-based
+// position in the staged file.
+type AddedLine struct {
+	Number int
+	Text   string
+}
+
+// FileDiff holds the lines a staged file adds on top of HEAD.
+type FileDiff struct {
+	Path  string
+	Added []AddedLine
+}
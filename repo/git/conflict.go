@@ -0,0 +1,342 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// conflictSides are the three versions of the files of a stopped merge or
+// rebase: ours is what the branch had, theirs the change being brought in.
+type conflictSides struct {
+	base, ours, theirs     map[string]treeFile
+	oursLabel, theirsLabel string
+	rebase                 *model.Rebase
+}
+
+// conflictSides loads the versions of the stopped rebase, or else of the merge
+// in progress, and fails with NoMerge when there is neither.
+func (g *Git) conflictSides() (*conflictSides, error) {
+	state, err := g.RebaseState()
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		c, err := g.repo.CommitObject(plumbing.NewHash(state.Todo[0]))
+		if err != nil {
+			return nil, err
+		}
+		tip, err := g.repo.CommitObject(plumbing.NewHash(state.Tip))
+		if err != nil {
+			return nil, err
+		}
+		sides := &conflictSides{base: map[string]treeFile{}, oursLabel: "onto", theirsLabel: c.Hash.String()[:7], rebase: state}
+		if len(c.ParentHashes) > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return nil, err
+			}
+			sides.base, err = g.commitFiles(parent)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sides.ours, err = g.commitFiles(tip)
+		if err != nil {
+			return nil, err
+		}
+		sides.theirs, err = g.commitFiles(c)
+		return sides, err
+	}
+
+	head, mergeHead, err := g.mergeHeads()
+	if err != nil {
+		return nil, err
+	}
+	sides := &conflictSides{base: map[string]treeFile{}, oursLabel: "HEAD", theirsLabel: mergeHead.Hash.String()[:7]}
+	bases, err := head.MergeBase(mergeHead)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) > 0 {
+		sides.base, err = g.commitFiles(bases[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	sides.ours, err = g.commitFiles(head)
+	if err != nil {
+		return nil, err
+	}
+	sides.theirs, err = g.commitFiles(mergeHead)
+	return sides, err
+}
+
+// mergeHeads returns HEAD and MERGE_HEAD of the merge in progress.
+func (g *Git) mergeHeads() (head, mergeHead *object.Commit, err error) {
+	data, err := os.ReadFile(g.gitPath(mergeHeadFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, g.err.NoMerge
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	mergeHead, err = g.repo.CommitObject(plumbing.NewHash(strings.TrimSpace(string(data))))
+	if err != nil {
+		return nil, nil, err
+	}
+	head, err = g.commitObject("HEAD")
+	return head, mergeHead, err
+}
+
+// Conflicts lists the files of the stopped merge or rebase that both sides
+// changed and could not be merged.
+func (g *Git) Conflicts() ([]*model.Conflict, error) {
+	sides, err := g.conflictSides()
+	if err != nil {
+		return nil, err
+	}
+	if sides.rebase != nil {
+		return sides.rebase.Conflicts, nil
+	}
+	oursChanges := changedFiles(sides.base, sides.ours)
+	conflicts := []*model.Conflict{}
+	for path, theirs := range changedFiles(sides.base, sides.theirs) {
+		if _, ok := oursChanges[path]; !ok {
+			continue
+		}
+		_, kind, err := g.mergeFile(lookup(sides.base, path), lookup(sides.ours, path), theirs, sides.oursLabel, sides.theirsLabel)
+		if err != nil {
+			return nil, err
+		}
+		if kind != "" {
+			conflicts = append(conflicts, &model.Conflict{Path: path, Kind: kind})
+		}
+	}
+	sortConflicts(conflicts)
+	return conflicts, nil
+}
+
+// ConflictView shows the conflicting parts of path with ours, the base and
+// theirs one after the other, as git's diff3 conflict style does. Files that
+// are not text are only described.
+func (g *Git) ConflictView(path string) (string, error) {
+	sides, err := g.conflictSides()
+	if err != nil {
+		return "", err
+	}
+	base, ours, theirs := lookup(sides.base, path), lookup(sides.ours, path), lookup(sides.theirs, path)
+	describe := func(label string, file *treeFile) string {
+		switch {
+		case file == nil:
+			return fmt.Sprintf("%s: deleted", label)
+		case file.mode == filemode.Submodule:
+			return fmt.Sprintf("%s: submodule at %s", label, file.hash.String()[:7])
+		case file.mode == filemode.Symlink:
+			return fmt.Sprintf("%s: symlink %s", label, file.hash.String()[:7])
+		}
+		return fmt.Sprintf("%s: binary %s", label, file.hash.String()[:7])
+	}
+	contents := [][]byte{}
+	for _, file := range []*treeFile{base, ours, theirs} {
+		if file != nil && !isBlob(file) {
+			contents = nil
+			break
+		}
+		content, err := g.fileContent(file)
+		if err != nil {
+			return "", err
+		}
+		if isBinary(content) {
+			contents = nil
+			break
+		}
+		contents = append(contents, content)
+	}
+	if contents == nil {
+		return strings.Join([]string{
+			describe(sides.oursLabel, ours),
+			describe("base", base),
+			describe(sides.theirsLabel, theirs),
+		}, "\n") + "\n", nil
+	}
+	view, _ := merge3(string(contents[0]), string(contents[1]), string(contents[2]), sides.oursLabel, sides.theirsLabel, "base")
+	return view, nil
+}
+
+// ResolveConflict writes our or their version of path to the working tree,
+// removing the file when that side deleted it.
+func (g *Git) ResolveConflict(path string, theirs bool) error {
+	sides, err := g.conflictSides()
+	if err != nil {
+		return err
+	}
+	file := lookup(sides.ours, path)
+	if theirs {
+		file = lookup(sides.theirs, path)
+	}
+	return g.writeWorktreeFile(path, file)
+}
+
+// HasConflictMarkers reports whether the working tree version of path still
+// holds a conflict block.
+func (g *Git) HasConflictMarkers(path string) (bool, error) {
+	content, err := os.ReadFile(g.worktreePath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return hasConflictMarkers(content), nil
+}
+
+// indexFiles maps the path of every resolved index entry to its entry.
+func (g *Git) indexFiles() (map[string]treeFile, error) {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]treeFile{}
+	for _, e := range idx.Entries {
+		if e.Stage == 0 {
+			files[e.Name] = treeFile{mode: e.Mode, hash: e.Hash}
+		}
+	}
+	return files, nil
+}
+
+// MergeContinue commits the merge in progress with the working tree version
+// of the conflicting files. It fails with ConflictsRemain while one of them
+// still holds conflict markers.
+func (g *Git) MergeContinue(committer *model.Identity) (*model.Merge, error) {
+	head, mergeHead, err := g.mergeHeads()
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := g.Conflicts()
+	if err != nil {
+		return nil, err
+	}
+	resolved := map[string]*treeFile{}
+	for _, c := range conflicts {
+		file, err := g.readWorktreeFile(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		content, err := g.fileContent(file)
+		if err != nil {
+			return nil, err
+		}
+		if hasConflictMarkers(content) {
+			return nil, fmt.Errorf("%w: %s", g.err.ConflictsRemain, c.Path)
+		}
+		resolved[c.Path] = file
+	}
+	files, err := g.indexFiles()
+	if err != nil {
+		return nil, err
+	}
+	treeHash, err := g.writeTree(applyChanges(files, resolved))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(g.gitPath(mergeMsgFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lines := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	message := strings.TrimSpace(strings.Join(lines, "\n"))
+	if message == "" {
+		message = fmt.Sprintf("Merge commit '%s'", mergeHead.Hash)
+	}
+
+	sig := signature(committer, time.Now())
+	if sig == nil {
+		sig = &object.Signature{Name: head.Committer.Name, Email: head.Committer.Email, When: time.Now()}
+	}
+	hash, err := g.writeCommit(&object.Commit{
+		Author:       *sig,
+		Committer:    *sig,
+		Message:      message + "\n",
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash, mergeHead.Hash},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.checkoutChanges(resolved)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := g.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	err = g.repo.Storer.SetReference(plumbing.NewHashReference(ref.Name(), hash))
+	if err != nil {
+		return nil, err
+	}
+	err = g.removeMergeState()
+	if err != nil {
+		return nil, err
+	}
+	return &model.Merge{Head: hash.String(), MergeHead: mergeHead.Hash.String(), Conflicts: conflicts}, nil
+}
+
+// MergeAbort puts the files the merge in progress changed back to their HEAD
+// version, in the index and the working tree, as git merge --abort does.
+// Other local changes are kept.
+func (g *Git) MergeAbort() error {
+	head, _, err := g.mergeHeads()
+	if err != nil {
+		return err
+	}
+	conflicts, err := g.Conflicts()
+	if err != nil {
+		return err
+	}
+	headFiles, err := g.commitFiles(head)
+	if err != nil {
+		return err
+	}
+	files, err := g.indexFiles()
+	if err != nil {
+		return err
+	}
+	restore := map[string]*treeFile{}
+	for path := range changedFiles(headFiles, files) {
+		restore[path] = lookup(headFiles, path)
+	}
+	for _, c := range conflicts {
+		restore[c.Path] = lookup(headFiles, c.Path)
+	}
+	err = g.checkoutChanges(restore)
+	if err != nil {
+		return err
+	}
+	return g.removeMergeState()
+}
+
+func (g *Git) removeMergeState() error {
+	for _, name := range []string{mergeHeadFile, mergeMsgFile} {
+		err := g.removeState(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,15 +1,23 @@
 package git
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v5"
 	gitCfg "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
@@ -23,26 +31,46 @@ const (
 )
 
 type Errors struct {
-	RemoteNotFound       error
-	RemoteNotLoaded      error
-	RemoteAlreadyExists  error
-	RepoAlreadyExists    error
-	RepoNotFound         error
-	PullFailed           error
-	AuthNotFound         error
-	InvalidAuthMethod    error
-	InvalidPassphrase    error
-	KeyNotSupported      error
-	AlreadyUpToDate      error
-	MergeFailed          error
-	RemoteBranchNotFound error
+	RemoteNotFound         error
+	RemoteNotLoaded        error
+	RemoteAlreadyExists    error
+	RepoAlreadyExists      error
+	RepoNotFound           error
+	PullFailed             error
+	AuthNotFound           error
+	InvalidAuthMethod      error
+	InvalidPassphrase      error
+	KeyNotSupported        error
+	AlreadyUpToDate        error
+	MergeFailed            error
+	RemoteBranchNotFound   error
+	SignKeyNotFound        error
+	SignFormatNotSupported error
+	CommitNotFound         error
+	AutosquashFailed       error
+	DetachedHead           error
+	RebaseConflict         error
+	RebaseInProgress       error
+	NoRebase               error
+	ConflictsRemain        error
+	MergeConflict          error
+	MergeInProgress        error
+	UnrelatedHistories     error
+	NoMerge                error
+	PushRejected           error
+	StaleLease             error
+	TagExists              error
 }
 
 type Git struct {
 	rootDir string
 	repo    *git.Repository
 	remote  *git.Remote
-	err     *Errors
+	signKey *openpgp.Entity
+	signer  git.Signer
+	// savedIndex is the index SaveIndex kept for RollbackRun.
+	savedIndex *index.Index
+	err        *Errors
 }
 
 func New(gitErrors *Errors) (*Git, error) {
@@ -66,13 +94,14 @@ func (g *Git) GetRepo() error {
 	return err
 }
 
+// CreateBranch creates the branch at HEAD. It tracks nothing until
+// SetUpstream is called after its first push.
 func (g *Git) CreateBranch(name model.Branch) error {
-	return g.repo.CreateBranch(&gitCfg.Branch{
-		Name:        name.String(),
-		Remote:      "origin",
-		Merge:       plumbing.NewBranchReferenceName(name.String()),
-		Description: fmt.Sprintf("Desc: %s", name),
-	})
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	return g.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name.String()), head.Hash()))
 }
 
 func (g *Git) CreateRepo() error {
@@ -114,6 +143,21 @@ func (g *Git) GetRemoteDetails() (*model.Remote, error) {
 	return remoteDetails, nil
 }
 
+// Remote returns the details of the remote called name.
+func (g *Git) Remote(name string) (*model.Remote, error) {
+	r, err := g.repo.Remote(name)
+	if errors.Is(err, git.ErrRemoteNotFound) {
+		return nil, g.err.RemoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model.Remote{
+		Name: r.Config().Name,
+		Url:  r.Config().URLs[0],
+	}, nil
+}
+
 func (g *Git) AddRemote(remote *model.Remote) error {
 	r, err := g.repo.CreateRemote(&gitCfg.RemoteConfig{
 		Name:   remote.Name,
@@ -127,45 +171,96 @@ func (g *Git) AddRemote(remote *model.Remote) error {
 	return err
 }
 
-func (g *Git) Pull(remote *model.Remote, branch model.Branch, auth *config.Credentials, force bool) error {
-
-	if auth == nil {
-		return g.err.AuthNotFound
-	}
-	w, err := g.repo.Worktree()
-	if err != nil {
-		return err
-	}
-	var Auth transport.AuthMethod
-	if remote.AuthMode() == model.AuthHTTP {
-		Auth = &http.BasicAuth{
+// authMethod builds the transport auth for remote, basic auth with the token
+// over http and the gopush key unlocked by the passphrase in auth.Token over ssh.
+func (g *Git) authMethod(remote *model.Remote, auth *config.Credentials) (transport.AuthMethod, error) {
+	switch remote.AuthMode() {
+	case model.AuthHTTP:
+		return &http.BasicAuth{
 			Username: auth.Username,
 			Password: auth.Token,
-		}
-	} else if remote.AuthMode() == model.AuthSSH {
+		}, nil
+	case model.AuthSSH:
 		sshPath := filepath.Join(os.Getenv("HOME"), gopushDir, keyName)
 		sshKey, _ := os.ReadFile(sshPath)
 		publicKey, err := ssh.NewPublicKeys("git", sshKey, auth.Token)
 		if err != nil {
 			if strings.Contains(err.Error(), "decryption password incorrect") {
-				return g.err.InvalidPassphrase
+				return nil, g.err.InvalidPassphrase
 			}
-			return err
+			return nil, err
 		}
-		Auth = publicKey
-	} else {
-		return g.err.InvalidAuthMethod
+		return publicKey, nil
+	}
+	return nil, g.err.InvalidAuthMethod
+}
+
+// Fetch updates the remote-tracking ref of branch from remote without
+// touching the current branch.
+func (g *Git) Fetch(ctx context.Context, remote *model.Remote, branch model.Branch, auth *config.Credentials, progress io.Writer) error {
+	if auth == nil {
+		return g.err.AuthNotFound
+	}
+	if g.remote == nil {
+		return g.err.RemoteNotLoaded
+	}
+	Auth, err := g.authMethod(remote, auth)
+	if err != nil {
+		return err
+	}
+	err = g.remote.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remote.Name,
+		RemoteURL:  remote.Url,
+		RefSpecs: []gitCfg.RefSpec{
+			gitCfg.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remote.Name, branch)),
+		},
+		Auth:     Auth,
+		Progress: progress,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return g.err.KeyNotSupported
+		}
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return g.err.AlreadyUpToDate
+		}
+		if errors.Is(err, git.NoMatchingRefSpecError{}) {
+			return g.err.RemoteBranchNotFound
+		}
+		return fmt.Errorf("%w: %v", g.err.PullFailed, err)
+	}
+	return nil
+}
+
+func (g *Git) Pull(ctx context.Context, remote *model.Remote, branch model.Branch, auth *config.Credentials, force bool, progress io.Writer) error {
+	if auth == nil {
+		return g.err.AuthNotFound
 	}
-	err = w.Pull(&git.PullOptions{
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	Auth, err := g.authMethod(remote, auth)
+	if err != nil {
+		return err
+	}
+	err = w.PullContext(ctx, &git.PullOptions{
 		RemoteName:    remote.Name,
 		RemoteURL:     remote.Url,
 		ReferenceName: plumbing.NewBranchReferenceName(branch.String()),
 		SingleBranch:  true,
 		Auth:          Auth,
 		Force:         force,
+		Progress:      progress,
 	})
 
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if strings.Contains(err.Error(), "unable to authenticate") {
 			return g.err.KeyNotSupported
 		}
@@ -221,62 +316,279 @@ func (g *Git) ChangeOccured() (bool, error) {
 	return !status.IsClean() || status.IsUntracked(g.rootDir), nil
 }
 
-func (g *Git) AddThenCommit(commitMsg string) error {
+func (g *Git) Status() ([]*model.FileChange, error) {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	changes := []*model.FileChange{}
+	for path, fs := range status {
+		if fs.Staging == git.Unmodified && fs.Worktree == git.Unmodified {
+			continue
+		}
+		changes = append(changes, &model.FileChange{
+			Path:     path,
+			Staging:  model.StatusCode(fs.Staging),
+			Worktree: model.StatusCode(fs.Worktree),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func (g *Git) AddAll() error {
 	w, err := g.repo.Worktree()
 	if err != nil {
 		return err
 	}
 	_, err = w.Add(".")
+	return err
+}
+
+// Commit records the staged index, files that are not staged are left out.
+// With amend it replaces HEAD and keeps its author, as git does. The commit
+// is signed when a signer was set.
+func (g *Git) Commit(commitMsg string, author, committer *model.Identity, amend bool) error {
+	w, err := g.repo.Worktree()
 	if err != nil {
 		return err
 	}
+	now := time.Now()
+	authorSig := signature(author, now)
+	if amend {
+		head, err := g.commitObject("HEAD")
+		if err != nil {
+			return err
+		}
+		authorSig = &head.Author
+	}
 	_, err = w.Commit(commitMsg, &git.CommitOptions{
-		All:               true,
-		AllowEmptyCommits: false,
-		Amend:             false,
+		All: false,
+		// amending only the message keeps the tree
+		AllowEmptyCommits: amend,
+		Amend:             amend,
+		Author:            authorSig,
+		Committer:         signature(committer, now),
+		Signer:            g.signer,
 	})
 	return err
 }
 
-func (g *Git) Push(remote *model.Remote, branch model.Branch, auth *config.Credentials, force bool) error {
-	if auth == nil {
-		return g.err.AuthNotFound
+// signature is nil for a nil identity so go-git falls back to git config.
+func signature(identity *model.Identity, when time.Time) *object.Signature {
+	if identity == nil {
+		return nil
 	}
-	if remote == nil {
-		return g.err.RemoteNotLoaded
+	return &object.Signature{
+		Name:  identity.Name,
+		Email: identity.Email,
+		When:  when,
 	}
-	var Auth transport.AuthMethod
-	if remote.AuthMode() == model.AuthHTTP {
-		Auth = &http.BasicAuth{
-			Username: auth.Username,
-			Password: auth.Token,
+}
+
+// ExportIndex writes the tree staged in the index to dir, giving a checkout of
+// exactly what the next commit will contain.
+func (g *Git) ExportIndex(dir string) error {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for _, e := range idx.Entries {
+		// stage 0 is a resolved entry, 1-3 are the sides of a conflict
+		if e.Stage != 0 || e.Mode == filemode.Submodule {
+			continue
 		}
-	} else if remote.AuthMode() == model.AuthSSH {
-		sshPath := filepath.Join(os.Getenv("HOME"), gopushDir, keyName)
-		sshKey, _ := os.ReadFile(sshPath)
-		publicKey, err := ssh.NewPublicKeys("git", sshKey, auth.Token)
+		path := filepath.Join(dir, filepath.FromSlash(e.Name))
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			return err
+		}
+		err = g.writeBlob(e.Hash, e.Mode, path)
 		if err != nil {
 			return err
 		}
-		Auth = publicKey
-	} else {
-		return g.err.InvalidAuthMethod
 	}
-	err := g.remote.Push(&git.PushOptions{
+	return nil
+}
+
+func (g *Git) writeBlob(hash plumbing.Hash, mode filemode.FileMode, path string) error {
+	blob, err := g.repo.BlobObject(hash)
+	if err != nil {
+		return err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if mode == filemode.Symlink {
+		target, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(string(target), path)
+	}
+
+	perm := os.FileMode(0644)
+	if mode == filemode.Executable {
+		perm = 0755
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Push updates remoteBranch on remote with branch, along with tags, in one
+// atomic push when the remote supports it. A push that is not a fast-forward
+// is rejected, unless forceWithLease is set and the remote branch is still
+// where it was when last fetched. Each push opens the repository on its own,
+// so pushes to several remotes can run concurrently.
+func (g *Git) Push(ctx context.Context, remote *model.Remote, branch, remoteBranch model.Branch, tags []string, auth *config.Credentials, forceWithLease bool, progress io.Writer) error {
+	if auth == nil {
+		return g.err.AuthNotFound
+	}
+	if remote == nil {
+		return g.err.RemoteNotLoaded
+	}
+	Auth, err := g.authMethod(remote, auth)
+	if err != nil {
+		return err
+	}
+	repo, err := git.PlainOpen(g.rootDir)
+	if err != nil {
+		return err
+	}
+	r, err := repo.Remote(remote.Name)
+	if err != nil {
+		return g.err.RemoteNotFound
+	}
+	opts := &git.PushOptions{
 		RemoteName: remote.Name,
 		RemoteURL:  remote.Url,
 		Prune:      false,
 		RefSpecs: []gitCfg.RefSpec{
 			// final refspecs
-			gitCfg.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/heads/%s", branch.String(), branch.String())),
+			gitCfg.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch.String(), remoteBranch.String())),
 		},
-		Force: force,
-		Auth:  Auth,
-	})
-	if err != nil && strings.Contains(err.Error(), "unable to authenticate") {
+		Auth:     Auth,
+		Progress: progress,
+		// go-git only asks for it when the remote advertises it
+		Atomic: true,
+	}
+	for _, tag := range tags {
+		opts.RefSpecs = append(opts.RefSpecs, gitCfg.RefSpec(fmt.Sprintf("refs/tags/%s:refs/tags/%s", tag, tag)))
+	}
+	if forceWithLease {
+		// a branch never fetched is new on the remote and needs no lease
+		_, err = repo.Storer.Reference(plumbing.NewRemoteReferenceName(remote.Name, remoteBranch.String()))
+		if err == nil {
+			opts.ForceWithLease = &git.ForceWithLease{}
+		} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return err
+		}
+	}
+	err = r.PushContext(ctx, opts)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil && strings.Contains(err.Error(), "unable to authenticate") {
 		return g.err.KeyNotSupported
 	} else if errors.Is(err, git.NoErrAlreadyUpToDate) {
 		return g.err.AlreadyUpToDate
+	} else if err != nil && strings.Contains(err.Error(), "non-fast-forward update") {
+		if opts.ForceWithLease != nil {
+			return g.err.StaleLease
+		}
+		return g.err.PushRejected
 	}
 	return err
 }
+
+// CheckPush asks remote where remoteBranch is and tells whether Push would be
+// accepted, without sending anything.
+func (g *Git) CheckPush(ctx context.Context, remote *model.Remote, branch, remoteBranch model.Branch, auth *config.Credentials, forceWithLease bool) error {
+	if auth == nil {
+		return g.err.AuthNotFound
+	}
+	Auth, err := g.authMethod(remote, auth)
+	if err != nil {
+		return err
+	}
+	repo, err := git.PlainOpen(g.rootDir)
+	if err != nil {
+		return err
+	}
+	r, err := repo.Remote(remote.Name)
+	if err != nil {
+		return g.err.RemoteNotFound
+	}
+	refs, err := r.ListContext(ctx, &git.ListOptions{Auth: Auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return g.err.KeyNotSupported
+		}
+		return err
+	}
+	name := plumbing.NewBranchReferenceName(remoteBranch.String())
+	remoteHash := plumbing.ZeroHash
+	for _, ref := range refs {
+		if ref.Name() == name {
+			remoteHash = ref.Hash()
+		}
+	}
+	if remoteHash.IsZero() {
+		return nil
+	}
+	local, err := repo.Reference(plumbing.NewBranchReferenceName(branch.String()), true)
+	if err != nil {
+		return err
+	}
+	if local.Hash() == remoteHash {
+		return nil
+	}
+	if forceWithLease {
+		tracking, err := repo.Reference(plumbing.NewRemoteReferenceName(remote.Name, remoteBranch.String()), true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) || (err == nil && tracking.Hash() != remoteHash) {
+			return g.err.StaleLease
+		}
+		return err
+	}
+	c, err := repo.CommitObject(remoteHash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		// never fetched, the remote has commits we do not
+		return g.err.PushRejected
+	}
+	if err != nil {
+		return err
+	}
+	head, err := repo.CommitObject(local.Hash())
+	if err != nil {
+		return err
+	}
+	ok, err := c.IsAncestor(head)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return g.err.PushRejected
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package git
+
+import (
+	gitCfg "github.com/go-git/go-git/v5/config"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// Identities reads the author and committer from git config. author.* and
+// committer.* take precedence over user.* as they do in git. Only the
+// repository config is read when local is set, otherwise it is merged with
+// the global and system config.
+func (g *Git) Identities(local bool) (author, committer *model.Identity, err error) {
+	scope := gitCfg.SystemScope
+	if local {
+		scope = gitCfg.LocalScope
+	}
+	cfg, err := g.repo.ConfigScoped(scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	user := &model.Identity{
+		Name:  cfg.Raw.Section("user").Option("name"),
+		Email: cfg.Raw.Section("user").Option("email"),
+	}
+	identity := func(section string) *model.Identity {
+		i := &model.Identity{
+			Name:  cfg.Raw.Section(section).Option("name"),
+			Email: cfg.Raw.Section(section).Option("email"),
+		}
+		if i.Name == "" {
+			i.Name = user.Name
+		}
+		if i.Email == "" {
+			i.Email = user.Email
+		}
+		return i
+	}
+	return identity("author"), identity("committer"), nil
+}
@@ -0,0 +1,626 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/seriouspoop/gopush/model"
+)
+
+const (
+	conflictStart = "<<<<<<< "
+	conflictBase  = "||||||| "
+	conflictSep   = "======="
+	conflictEnd   = ">>>>>>> "
+)
+
+func sameFile(a, b *treeFile) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// mergeFile merges two changes of a file made from base, a nil version is a
+// missing file. Text files are merged line by line, changes to the same lines
+// are written between conflict markers. A file changed on one side and deleted
+// on the other keeps the changed version, binary files, symlinks and
+// submodules keep ours. The kind of conflict is empty for a clean merge.
+func (g *Git) mergeFile(base, ours, theirs *treeFile, oursLabel, theirsLabel string) (*treeFile, model.ConflictKind, error) {
+	switch {
+	case sameFile(ours, theirs):
+		return ours, "", nil
+	case sameFile(base, ours):
+		return theirs, "", nil
+	case sameFile(base, theirs):
+		return ours, "", nil
+	case ours == nil:
+		return theirs, model.ConflictDeleted, nil
+	case theirs == nil:
+		return ours, model.ConflictDeleted, nil
+	case !isBlob(ours) || !isBlob(theirs) || (base != nil && !isBlob(base)):
+		return ours, model.ConflictBinary, nil
+	}
+
+	baseContent, err := g.fileContent(base)
+	if err != nil {
+		return nil, "", err
+	}
+	oursContent, err := g.fileContent(ours)
+	if err != nil {
+		return nil, "", err
+	}
+	theirsContent, err := g.fileContent(theirs)
+	if err != nil {
+		return nil, "", err
+	}
+	if isBinary(baseContent) || isBinary(oursContent) || isBinary(theirsContent) {
+		return ours, model.ConflictBinary, nil
+	}
+
+	merged, conflicts := merge3(string(baseContent), string(oursContent), string(theirsContent), oursLabel, theirsLabel, "")
+	hash, err := g.writeBlobObject([]byte(merged))
+	if err != nil {
+		return nil, "", err
+	}
+	// a mode change on one side is kept
+	mode := ours.mode
+	if base != nil && ours.mode == base.mode {
+		mode = theirs.mode
+	}
+	kind := model.ConflictKind("")
+	if conflicts > 0 {
+		kind = model.ConflictContent
+		if base == nil {
+			kind = model.ConflictAdded
+		}
+	}
+	return &treeFile{mode: mode, hash: hash}, kind, nil
+}
+
+func sortConflicts(conflicts []*model.Conflict) {
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+}
+
+func isBlob(file *treeFile) bool {
+	return file.mode == filemode.Regular || file.mode == filemode.Executable || file.mode == filemode.Deprecated
+}
+
+// lineChange replaces the base lines [start, end) with lines.
+type lineChange struct {
+	start, end int
+	lines      []string
+	theirs     bool
+}
+
+// lineChanges turns a line diff of base into the changes it makes to base.
+func lineChanges(diffs []diffmatchpatch.Diff, theirs bool) []*lineChange {
+	changes := []*lineChange{}
+	var current *lineChange
+	pos := 0
+	for _, d := range diffs {
+		lines := splitLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			current = nil
+			pos += len(lines)
+			continue
+		}
+		if current == nil {
+			current = &lineChange{start: pos, end: pos, theirs: theirs}
+			changes = append(changes, current)
+		}
+		if d.Type == diffmatchpatch.DiffDelete {
+			current.end += len(lines)
+			pos += len(lines)
+		} else {
+			current.lines = append(current.lines, lines...)
+		}
+	}
+	return changes
+}
+
+// merge3 applies the changes ours and theirs made to base. Changes touching
+// the same or adjacent base lines conflict unless they are identical. It
+// returns the merged text and the number of conflict blocks in it. With a
+// baseLabel the base lines are shown in conflict blocks too, as in git's
+// diff3 conflict style.
+func merge3(base, ours, theirs, oursLabel, theirsLabel, baseLabel string) (string, int) {
+	baseLines := splitLines(base)
+	changes := append(lineChanges(diff.Do(base, ours), false), lineChanges(diff.Do(base, theirs), true)...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].start < changes[j].start
+	})
+
+	var b strings.Builder
+	conflicts := 0
+	pos := 0
+	for i := 0; i < len(changes); {
+		start, end := changes[i].start, changes[i].end
+		j := i + 1
+		for ; j < len(changes) && changes[j].start <= end; j++ {
+			end = max(end, changes[j].end)
+		}
+		group := changes[i:j]
+		i = j
+
+		for _, l := range baseLines[pos:start] {
+			b.WriteString(l)
+		}
+		pos = end
+		oursLines, oursChanged := applyLineChanges(baseLines, start, end, group, false)
+		theirsLines, theirsChanged := applyLineChanges(baseLines, start, end, group, true)
+		switch {
+		case !theirsChanged || slices.Equal(oursLines, theirsLines):
+			writeLines(&b, oursLines, false)
+		case !oursChanged:
+			writeLines(&b, theirsLines, false)
+		default:
+			conflicts++
+			b.WriteString(conflictStart + oursLabel + "\n")
+			writeLines(&b, oursLines, true)
+			if baseLabel != "" {
+				b.WriteString(conflictBase + baseLabel + "\n")
+				writeLines(&b, baseLines[start:end], true)
+			}
+			b.WriteString(conflictSep + "\n")
+			writeLines(&b, theirsLines, true)
+			b.WriteString(conflictEnd + theirsLabel + "\n")
+		}
+	}
+	for _, l := range baseLines[pos:] {
+		b.WriteString(l)
+	}
+	return b.String(), conflicts
+}
+
+// applyLineChanges is the base lines [start, end) with the changes of one side
+// in group applied, changed is false when that side has none.
+func applyLineChanges(baseLines []string, start, end int, group []*lineChange, theirs bool) (lines []string, changed bool) {
+	pos := start
+	for _, c := range group {
+		if c.theirs != theirs {
+			continue
+		}
+		changed = true
+		lines = append(lines, baseLines[pos:c.start]...)
+		lines = append(lines, c.lines...)
+		pos = c.end
+	}
+	return append(lines, baseLines[pos:end]...), changed
+}
+
+// writeLines writes lines, ending the last one with a newline inside a
+// conflict block so the markers stay on their own line.
+func writeLines(b *strings.Builder, lines []string, block bool) {
+	for _, l := range lines {
+		b.WriteString(l)
+	}
+	if block && len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		b.WriteString("\n")
+	}
+}
+
+// fileContent reads the blob of file, a missing file is empty.
+func (g *Git) fileContent(file *treeFile) ([]byte, error) {
+	if file == nil {
+		return nil, nil
+	}
+	return g.readBlob(file.hash)
+}
+
+// hasConflictMarkers reports whether content still holds a conflict block.
+func hasConflictMarkers(content []byte) bool {
+	start, sep := false, false
+	for _, line := range strings.Split(string(content), "\n") {
+		switch {
+		case strings.HasPrefix(line, conflictStart):
+			start = true
+		case start && line == conflictSep:
+			sep = true
+		case sep && strings.HasPrefix(line, conflictEnd):
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Git) worktreePath(path string) string {
+	return filepath.Join(g.rootDir, filepath.FromSlash(path))
+}
+
+// writeWorktreeFile writes file to path in the working tree, nil removes it
+// along with the directories it leaves empty.
+func (g *Git) writeWorktreeFile(path string, file *treeFile) error {
+	full := g.worktreePath(path)
+	err := os.Remove(full)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if file == nil {
+		for dir := filepath.Dir(full); dir != g.rootDir; dir = filepath.Dir(dir) {
+			// fails on the first directory that is not empty
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+		return nil
+	}
+	err = os.MkdirAll(filepath.Dir(full), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return g.writeBlob(file.hash, file.mode, full)
+}
+
+// resetHard moves the current branch, the index and the working tree to the
+// commit hash. Unlike a go-git hard reset it leaves untracked files alone.
+func (g *Git) resetHard(hash plumbing.Hash) error {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	tracked := map[string]bool{}
+	for _, e := range idx.Entries {
+		tracked[e.Name] = true
+	}
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	err = w.Reset(&git.ResetOptions{Commit: hash, Mode: git.MixedReset})
+	if err != nil {
+		return err
+	}
+	c, err := g.repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+	files, err := g.flattenTree(tree)
+	if err != nil {
+		return err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+	for path, s := range status {
+		if s.Worktree == git.Unmodified || (s.Worktree == git.Untracked && !tracked[path]) {
+			continue
+		}
+		err = g.writeWorktreeFile(path, lookup(files, path))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readWorktreeFile stores the working tree version of path as a blob, nil
+// when the file is missing.
+func (g *Git) readWorktreeFile(path string) (*treeFile, error) {
+	full := g.worktreePath(path)
+	info, err := os.Lstat(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	mode := filemode.Regular
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(full)
+		if err != nil {
+			return nil, err
+		}
+		content, mode = []byte(target), filemode.Symlink
+	default:
+		content, err = os.ReadFile(full)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode()&0111 != 0 {
+			mode = filemode.Executable
+		}
+	}
+	hash, err := g.writeBlobObject(content)
+	if err != nil {
+		return nil, err
+	}
+	return &treeFile{mode: mode, hash: hash}, nil
+}
+
+const (
+	mergeHeadFile = "MERGE_HEAD"
+	mergeMsgFile  = "MERGE_MSG"
+)
+
+// Merge merges the remote-tracking ref of upstream, as of the last fetch, into
+// the current branch. The branch is fast-forwarded when it has no commits of
+// its own, otherwise the three-way merge with the merge base is committed.
+// Local changes are kept unless the merge touches the same files.
+//
+// On conflicts nothing is committed: cleanly merged files are staged, the
+// conflicting ones are written to the working tree with conflict markers and
+// to the index as unmerged entries, MERGE_HEAD is set as git merge does, and
+// MergeConflict is returned. Histories without a merge base are refused with
+// UnrelatedHistories.
+func (g *Git) Merge(upstream *model.Upstream, committer *model.Identity) (*model.Merge, error) {
+	_, err := os.Stat(g.gitPath(mergeHeadFile))
+	if err == nil {
+		return nil, g.err.MergeInProgress
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if !head.Name().IsBranch() {
+		return nil, g.err.DetachedHead
+	}
+	onto, err := g.repo.Reference(plumbing.NewRemoteReferenceName(upstream.Remote, upstream.Branch.String()), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, g.err.RemoteBranchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if head.Hash() == onto.Hash() {
+		return nil, g.err.AlreadyUpToDate
+	}
+	headCommit, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	ontoCommit, err := g.repo.CommitObject(onto.Hash())
+	if err != nil {
+		return nil, err
+	}
+	merged, err := ontoCommit.IsAncestor(headCommit)
+	if err != nil {
+		return nil, err
+	}
+	if merged {
+		return nil, g.err.AlreadyUpToDate
+	}
+
+	result := &model.Merge{Head: head.Hash().String(), MergeHead: onto.Hash().String()}
+	headFiles, err := g.commitFiles(headCommit)
+	if err != nil {
+		return nil, err
+	}
+	ontoFiles, err := g.commitFiles(ontoCommit)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := headCommit.MergeBase(ontoCommit)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%w: %s", g.err.UnrelatedHistories, upstream)
+	}
+	if bases[0].Hash == headCommit.Hash {
+		changes := changedFiles(headFiles, ontoFiles)
+		err = g.checkLocalChanges(changes)
+		if err != nil {
+			return nil, err
+		}
+		err = g.checkoutChanges(changes)
+		if err != nil {
+			return nil, err
+		}
+		result.FastForward = true
+		return result, g.repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), onto.Hash()))
+	}
+	baseFiles, err := g.commitFiles(bases[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// changes and the conflicting files are relative to HEAD
+	oursChanges := changedFiles(baseFiles, headFiles)
+	changes := map[string]*treeFile{}
+	conflicting := map[string]*treeFile{}
+	for path, theirs := range changedFiles(baseFiles, ontoFiles) {
+		ours := lookup(headFiles, path)
+		if _, ok := oursChanges[path]; !ok {
+			changes[path] = theirs
+			continue
+		}
+		file, kind, err := g.mergeFile(lookup(baseFiles, path), ours, theirs, "HEAD", upstream.String())
+		if err != nil {
+			return nil, err
+		}
+		if kind != "" {
+			result.Conflicts = append(result.Conflicts, &model.Conflict{Path: path, Kind: kind})
+			conflicting[path] = file
+		} else if !sameFile(file, ours) {
+			changes[path] = file
+		}
+	}
+	err = g.checkLocalChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+	err = g.checkLocalChanges(conflicting)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Merge remote-tracking branch '%s' into %s\n", upstream, head.Name().Short())
+	if len(result.Conflicts) > 0 {
+		sortConflicts(result.Conflicts)
+		err = g.checkoutChanges(changes)
+		if err != nil {
+			return nil, err
+		}
+		for path, file := range conflicting {
+			err = g.writeWorktreeFile(path, file)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = g.setUnmerged(conflicting, baseFiles, headFiles, ontoFiles)
+		if err != nil {
+			return nil, err
+		}
+		message += "\n# Conflicts:\n"
+		for _, c := range result.Conflicts {
+			message += "#\t" + c.Path + "\n"
+		}
+		err = os.WriteFile(g.gitPath(mergeMsgFile), []byte(message), 0644)
+		if err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(g.gitPath(mergeHeadFile), []byte(onto.Hash().String()+"\n"), 0644)
+		if err != nil {
+			return nil, err
+		}
+		return result, g.err.MergeConflict
+	}
+
+	treeHash, err := g.writeTree(applyChanges(headFiles, changes))
+	if err != nil {
+		return nil, err
+	}
+	sig := signature(committer, time.Now())
+	if sig == nil {
+		sig = &object.Signature{Name: headCommit.Committer.Name, Email: headCommit.Committer.Email, When: time.Now()}
+	}
+	hash, err := g.writeCommit(&object.Commit{
+		Author:       *sig,
+		Committer:    *sig,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash(), onto.Hash()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.checkoutChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+	return result, g.repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), hash))
+}
+
+func (g *Git) commitFiles(c *object.Commit) (map[string]treeFile, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return g.flattenTree(tree)
+}
+
+// checkLocalChanges fails when one of the paths about to be written has
+// staged, unstaged or untracked changes, which the merge would overwrite.
+func (g *Git) checkLocalChanges(paths map[string]*treeFile) error {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+	local := []string{}
+	for path := range paths {
+		if s, ok := status[path]; ok && (s.Worktree != git.Unmodified || s.Staging != git.Unmodified) {
+			local = append(local, path)
+		}
+	}
+	if len(local) > 0 {
+		sort.Strings(local)
+		return fmt.Errorf("%w: local changes would be overwritten in %s", g.err.MergeFailed, strings.Join(local, ", "))
+	}
+	return nil
+}
+
+// checkoutChanges writes the changed files to the working tree and the index.
+func (g *Git) checkoutChanges(changes map[string]*treeFile) error {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for path, file := range changes {
+		err = g.writeWorktreeFile(path, file)
+		if err != nil {
+			return err
+		}
+		removeEntries(idx, path)
+		if file == nil {
+			continue
+		}
+		e := idx.Add(path)
+		e.Hash = file.hash
+		e.Mode = file.mode
+	}
+	sortIndex(idx)
+	return g.repo.Storer.SetIndex(idx)
+}
+
+// setUnmerged replaces the index entry of each conflicting path with its base,
+// ours and theirs versions at stages 1, 2 and 3, as git merge does, so that
+// git status lists them as unmerged. A side without the path has no entry.
+func (g *Git) setUnmerged(paths map[string]*treeFile, base, ours, theirs map[string]treeFile) error {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	stages := []index.Stage{index.AncestorMode, index.OurMode, index.TheirMode}
+	for path := range paths {
+		removeEntries(idx, path)
+		for i, side := range []map[string]treeFile{base, ours, theirs} {
+			file, ok := side[path]
+			if !ok {
+				continue
+			}
+			e := idx.Add(path)
+			e.Hash = file.hash
+			e.Mode = file.mode
+			e.Stage = stages[i]
+		}
+	}
+	sortIndex(idx)
+	return g.repo.Storer.SetIndex(idx)
+}
+
+// removeEntries drops every entry of path from idx, unmerged ones included.
+func removeEntries(idx *index.Index, path string) {
+	idx.Entries = slices.DeleteFunc(idx.Entries, func(e *index.Entry) bool {
+		return e.Name == path
+	})
+}
+
+// sortIndex orders the entries by path then stage as git requires. go-git
+// only sorts them by path when writing the index, leaving this order as is.
+func sortIndex(idx *index.Index) {
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		a, b := idx.Entries[i], idx.Entries[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Stage < b.Stage
+	})
+}
@@ -0,0 +1,221 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/seriouspoop/gopush/model"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflicts          int
+	}{
+		{
+			name: "separate edits",
+			base: "a\nb\nc\nd\n", ours: "A\nb\nc\nd\n", theirs: "a\nb\nc\nD\n",
+			want: "A\nb\nc\nD\n",
+		},
+		{
+			name: "adjacent edits",
+			base: "a\nb\nc\n", ours: "A\nb\nc\n", theirs: "a\nB\nc\n",
+			want:      "<<<<<<< ours\nA\nb\n=======\na\nB\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "identical edits",
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nB\nc\n",
+			want: "a\nB\nc\n",
+		},
+		{
+			name: "same line edited differently",
+			base: "a\nb\nc\n", ours: "a\nX\nc\n", theirs: "a\nY\nc\n",
+			want:      "a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "insert against delete at the same spot",
+			base: "a\nb\nc\n", ours: "a\nX\nb\nc\n", theirs: "a\nc\n",
+			want:      "a\n<<<<<<< ours\nX\nb\n=======\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "both insert at the end",
+			base: "a\n", ours: "a\nX\n", theirs: "a\nY\n",
+			want:      "a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name: "one side only",
+			base: "a\nb\n", ours: "a\nb\n", theirs: "a\nb\nc\n",
+			want: "a\nb\nc\n",
+		},
+		{
+			name: "final newline added away from an edit",
+			base: "a\nb\nc", ours: "A\nb\nc", theirs: "a\nb\nc\n",
+			want: "A\nb\nc\n",
+		},
+		{
+			name: "last line without newline edited differently",
+			base: "a\nb\nc", ours: "a\nb\nX", theirs: "a\nb\nY",
+			want:      "a\nb\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := merge3(tt.base, tt.ours, tt.theirs, "ours", "theirs", "")
+			if got != tt.want || conflicts != tt.conflicts {
+				t.Errorf("merge3() = %q, %d conflicts, want %q, %d conflicts", got, conflicts, tt.want, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestLineChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		base, to string
+		want     []lineChange
+	}{
+		{"unchanged", "a\nb\n", "a\nb\n", nil},
+		{"replace", "a\nb\nc\n", "a\nX\nc\n", []lineChange{{start: 1, end: 2, lines: []string{"X\n"}}}},
+		{"insert", "a\nb\n", "a\nX\nb\n", []lineChange{{start: 1, end: 1, lines: []string{"X\n"}}}},
+		{"delete", "a\nb\nc\n", "a\nc\n", []lineChange{{start: 1, end: 2}}},
+		{"two changes", "a\nb\nc\n", "X\nb\nY\n", []lineChange{
+			{start: 0, end: 1, lines: []string{"X\n"}},
+			{start: 2, end: 3, lines: []string{"Y\n"}},
+		}},
+		{"final newline", "a\nb", "a\nb\n", []lineChange{{start: 1, end: 2, lines: []string{"b\n"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lineChanges(diff.Do(tt.base, tt.to), true)
+			if len(got) != len(tt.want) {
+				t.Fatalf("lineChanges() = %d changes, want %d", len(got), len(tt.want))
+			}
+			for i, c := range got {
+				want := tt.want[i]
+				if c.start != want.start || c.end != want.end || !slices.Equal(c.lines, want.lines) || !c.theirs {
+					t.Errorf("change %d = %+v, want %+v", i, *c, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// resolved entries are at stage 0, go-git's merged is the base stage
+	const merged index.Stage = 0
+	tests := []struct {
+		name            string
+		upstream, local map[string]string
+		// unrelated moves origin/main to a root commit
+		unrelated bool
+		wantErr   error
+		wantFiles map[string]string
+		// wantStages are the index stages of each path after the merge
+		wantStages map[string][]index.Stage
+	}{
+		{
+			name:       "separate lines of a file",
+			upstream:   map[string]string{"a.txt": "one\n2\n3\n"},
+			local:      map[string]string{"a.txt": "1\n2\nthree\n"},
+			wantFiles:  map[string]string{"a.txt": "one\n2\nthree\n"},
+			wantStages: map[string][]index.Stage{"a.txt": {merged}, "b.txt": {merged}},
+		},
+		{
+			name:       "same line",
+			upstream:   map[string]string{"a.txt": "one\n2\n3\n", "b.txt": "b2\n"},
+			local:      map[string]string{"a.txt": "uno\n2\n3\n"},
+			wantErr:    errTestMergeConflict,
+			wantStages: map[string][]index.Stage{"a.txt": {index.AncestorMode, index.OurMode, index.TheirMode}, "b.txt": {merged}},
+		},
+		{
+			name:       "file added on both sides",
+			upstream:   map[string]string{"c.txt": "theirs\n"},
+			local:      map[string]string{"c.txt": "ours\n"},
+			wantErr:    errTestMergeConflict,
+			wantStages: map[string][]index.Stage{"c.txt": {index.OurMode, index.TheirMode}},
+		},
+		{
+			name:      "unrelated histories",
+			local:     map[string]string{"b.txt": "b2\n"},
+			unrelated: true,
+			wantErr:   errTestUnrelated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, r := rebaseRepo(t, tt.upstream, tt.local)
+			if tt.unrelated {
+				head, err := g.commitObject("HEAD")
+				if err != nil {
+					t.Fatal(err)
+				}
+				sig := object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+				root, err := g.writeCommit(&object.Commit{Author: sig, Committer: sig, Message: "root\n", TreeHash: head.TreeHash})
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "main"), root))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, mergeErr := g.Merge(&model.Upstream{Remote: "origin", Branch: "main"}, nil)
+			if !errors.Is(mergeErr, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", mergeErr, tt.wantErr)
+			}
+			for name, want := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(g.rootDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			idx, err := r.Storer.Index()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stages := map[string][]index.Stage{}
+			for _, e := range idx.Entries {
+				stages[e.Name] = append(stages[e.Name], e.Stage)
+			}
+			for name, want := range tt.wantStages {
+				if !slices.Equal(stages[name], want) {
+					t.Errorf("index stages of %s = %v, want %v", name, stages[name], want)
+				}
+			}
+			if !errors.Is(mergeErr, errTestMergeConflict) {
+				return
+			}
+			err = g.MergeAbort()
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx, err = r.Storer.Index()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range idx.Entries {
+				if e.Stage != merged {
+					t.Errorf("MergeAbort() left %s at stage %d", e.Name, e.Stage)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// Rebase replays the commits of the current branch missing from the
+// remote-tracking ref of upstream on top of it, as of the last fetch. Merge
+// commits are replayed as their change to the first parent, leaving a linear
+// history. The working tree must be clean, autostash is only recorded so the
+// caller can restore it once the rebase is over.
+//
+// On conflicts the branch is left at the last replayed commit with the
+// conflicting files written to the working tree, and RebaseConflict is
+// returned along with the state to continue or abort from.
+func (g *Git) Rebase(upstream *model.Upstream, autostash string, committer *model.Identity) (*model.Rebase, error) {
+	state, err := g.RebaseState()
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		return state, g.err.RebaseInProgress
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if !head.Name().IsBranch() {
+		return nil, g.err.DetachedHead
+	}
+	onto, err := g.repo.Reference(plumbing.NewRemoteReferenceName(upstream.Remote, upstream.Branch.String()), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, g.err.RemoteBranchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	upstreamSeen, err := g.ancestors(onto.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if head.Hash() == onto.Hash() {
+		return nil, g.err.AlreadyUpToDate
+	}
+
+	todo := []string{}
+	c, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	for !upstreamSeen[c.Hash] {
+		todo = append(todo, c.Hash.String())
+		if len(c.ParentHashes) == 0 {
+			break
+		}
+		c, err = c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	localSeen, err := g.ancestors(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if localSeen[onto.Hash()] {
+		// the branch is only ahead of upstream
+		return nil, g.err.AlreadyUpToDate
+	}
+	slices.Reverse(todo)
+
+	state = &model.Rebase{
+		Branch:    model.Branch(head.Name().Short()),
+		Onto:      onto.Hash().String(),
+		OrigHead:  head.Hash().String(),
+		Todo:      todo,
+		Tip:       onto.Hash().String(),
+		Autostash: autostash,
+	}
+	return g.replay(state, committer)
+}
+
+// replay picks the commits left in state.Todo one by one on top of state.Tip.
+func (g *Git) replay(state *model.Rebase, committer *model.Identity) (*model.Rebase, error) {
+	for len(state.Todo) > 0 {
+		c, err := g.repo.CommitObject(plumbing.NewHash(state.Todo[0]))
+		if err != nil {
+			return nil, err
+		}
+		tip, err := g.repo.CommitObject(plumbing.NewHash(state.Tip))
+		if err != nil {
+			return nil, err
+		}
+		tipTree, err := tip.Tree()
+		if err != nil {
+			return nil, err
+		}
+		tipFiles, err := g.flattenTree(tipTree)
+		if err != nil {
+			return nil, err
+		}
+		base := map[string]treeFile{}
+		if len(c.ParentHashes) > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return nil, err
+			}
+			parentTree, err := parent.Tree()
+			if err != nil {
+				return nil, err
+			}
+			base, err = g.flattenTree(parentTree)
+			if err != nil {
+				return nil, err
+			}
+		}
+		changes, err := g.commitChanges(c)
+		if err != nil {
+			return nil, err
+		}
+
+		merged := map[string]*treeFile{}
+		conflicts := []*model.Conflict{}
+		label := fmt.Sprintf("%s (%s)", c.Hash.String()[:7], (&model.Commit{Message: c.Message}).Subject())
+		for path, theirs := range changes {
+			file, kind, err := g.mergeFile(lookup(base, path), lookup(tipFiles, path), theirs, "onto", label)
+			if err != nil {
+				return nil, err
+			}
+			if kind != "" {
+				conflicts = append(conflicts, &model.Conflict{Path: path, Kind: kind})
+			}
+			merged[path] = file
+		}
+		if len(conflicts) > 0 {
+			sortConflicts(conflicts)
+			return state, g.stopRebase(state, tip.Hash, merged, conflicts)
+		}
+
+		err = g.pick(state, c, tip, applyChanges(tipFiles, merged), committer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return state, g.finishRebase(state)
+}
+
+// pick commits files on top of tip with the author and message of c, unless
+// the change of c is already in tip.
+func (g *Git) pick(state *model.Rebase, c, tip *object.Commit, files map[string]treeFile, committer *model.Identity) error {
+	treeHash, err := g.writeTree(files)
+	if err != nil {
+		return err
+	}
+	state.Todo = state.Todo[1:]
+	if treeHash == tip.TreeHash {
+		return nil
+	}
+	committerSig := signature(committer, time.Now())
+	if committerSig == nil {
+		committerSig = &object.Signature{Name: c.Committer.Name, Email: c.Committer.Email, When: time.Now()}
+	}
+	hash, err := g.writeCommit(&object.Commit{
+		Author:       c.Author,
+		Committer:    *committerSig,
+		Message:      c.Message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{tip.Hash},
+	})
+	if err != nil {
+		return err
+	}
+	state.Tip = hash.String()
+	state.Replayed++
+	return nil
+}
+
+// stopRebase moves the branch to tip and writes the merged files of the
+// commit in conflict to the working tree.
+func (g *Git) stopRebase(state *model.Rebase, tip plumbing.Hash, merged map[string]*treeFile, conflicts []*model.Conflict) error {
+	err := g.resetHard(tip)
+	if err != nil {
+		return err
+	}
+	for path, file := range merged {
+		err = g.writeWorktreeFile(path, file)
+		if err != nil {
+			return err
+		}
+	}
+	state.Conflicts = conflicts
+	err = g.writeState(rebaseStateFile, state)
+	if err != nil {
+		return err
+	}
+	return g.err.RebaseConflict
+}
+
+func (g *Git) finishRebase(state *model.Rebase) error {
+	err := g.resetHard(plumbing.NewHash(state.Tip))
+	if err != nil {
+		return err
+	}
+	return g.removeState(rebaseStateFile)
+}
+
+// RebaseContinue commits the working tree version of the files changed by the
+// commit the rebase stopped on and replays the rest. It fails with
+// ConflictsRemain while one of them still holds conflict markers.
+func (g *Git) RebaseContinue(committer *model.Identity) (*model.Rebase, error) {
+	state, err := g.RebaseState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, g.err.NoRebase
+	}
+	c, err := g.repo.CommitObject(plumbing.NewHash(state.Todo[0]))
+	if err != nil {
+		return nil, err
+	}
+	tip, err := g.repo.CommitObject(plumbing.NewHash(state.Tip))
+	if err != nil {
+		return nil, err
+	}
+	changes, err := g.commitChanges(c)
+	if err != nil {
+		return nil, err
+	}
+	resolved := map[string]*treeFile{}
+	for path := range changes {
+		file, err := g.readWorktreeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content, err := g.fileContent(file)
+		if err != nil {
+			return nil, err
+		}
+		if hasConflictMarkers(content) {
+			return state, fmt.Errorf("%w: %s", g.err.ConflictsRemain, path)
+		}
+		resolved[path] = file
+	}
+	tipTree, err := tip.Tree()
+	if err != nil {
+		return nil, err
+	}
+	tipFiles, err := g.flattenTree(tipTree)
+	if err != nil {
+		return nil, err
+	}
+	state.Conflicts = nil
+	err = g.pick(state, c, tip, applyChanges(tipFiles, resolved), committer)
+	if err != nil {
+		return nil, err
+	}
+	return g.replay(state, committer)
+}
+
+// RebaseAbort moves the branch and working tree back to where they were
+// before the rebase. The returned state holds the autostash to restore.
+func (g *Git) RebaseAbort() (*model.Rebase, error) {
+	state, err := g.RebaseState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, g.err.NoRebase
+	}
+	err = g.resetHard(plumbing.NewHash(state.OrigHead))
+	if err != nil {
+		return nil, err
+	}
+	return state, g.removeState(rebaseStateFile)
+}
@@ -0,0 +1,208 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/seriouspoop/gopush/model"
+)
+
+var (
+	errTestConflict      = errors.New("rebase conflict")
+	errTestMergeConflict = errors.New("merge conflict")
+	errTestUnrelated     = errors.New("unrelated histories")
+	errTestUpToDate      = errors.New("already up to date")
+	errTestNoUpstream    = errors.New("remote branch not found")
+)
+
+// rebaseRepo is a repository on main with a base commit, origin/main moved
+// to a commit writing upstream on top of it, and main to a commit writing
+// local. nil leaves that side at the base commit.
+func rebaseRepo(t *testing.T, upstream, local map[string]string) (*Git, *git.Repository) {
+	t.Helper()
+	dir := t.TempDir()
+	r, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.Main},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(files map[string]string, msg string) plumbing.Hash {
+		for name, content := range files {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = wt.Add(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+		hash, err := wt.Commit(msg, &git.CommitOptions{Author: sig, Committer: sig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	base := commit(map[string]string{"a.txt": "1\n2\n3\n", "b.txt": "b\n"}, "base")
+	onto := base
+	if upstream != nil {
+		onto = commit(upstream, "upstream")
+		err = wt.Reset(&git.ResetOptions{Commit: base, Mode: git.HardReset})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "main"), onto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local != nil {
+		commit(local, "local")
+	}
+	g := &Git{rootDir: dir, repo: r, err: &Errors{
+		AlreadyUpToDate:      errTestUpToDate,
+		RemoteBranchNotFound: errTestNoUpstream,
+		RebaseConflict:       errTestConflict,
+		RebaseInProgress:     errors.New("rebase in progress"),
+		DetachedHead:         errors.New("detached head"),
+		NoRebase:             errors.New("no rebase"),
+		ConflictsRemain:      errors.New("conflicts remain"),
+		MergeConflict:        errTestMergeConflict,
+		MergeInProgress:      errors.New("merge in progress"),
+		MergeFailed:          errors.New("merge failed"),
+		NoMerge:              errors.New("no merge"),
+		UnrelatedHistories:   errTestUnrelated,
+	}}
+	return g, r
+}
+
+func TestRebase(t *testing.T) {
+	tests := []struct {
+		name            string
+		upstream, local map[string]string
+		remote          string
+		wantErr         error
+		wantReplayed    int
+		wantConflicts   int
+		wantFiles       map[string]string
+	}{
+		{
+			name:         "separate files",
+			upstream:     map[string]string{"a.txt": "one\n2\n3\n"},
+			local:        map[string]string{"b.txt": "b2\n"},
+			wantReplayed: 1,
+			wantFiles:    map[string]string{"a.txt": "one\n2\n3\n", "b.txt": "b2\n"},
+		},
+		{
+			name:         "separate lines of a file",
+			upstream:     map[string]string{"a.txt": "one\n2\n3\n"},
+			local:        map[string]string{"a.txt": "1\n2\nthree\n"},
+			wantReplayed: 1,
+			wantFiles:    map[string]string{"a.txt": "one\n2\nthree\n"},
+		},
+		{
+			name:         "change already upstream",
+			upstream:     map[string]string{"a.txt": "one\n2\n3\n"},
+			local:        map[string]string{"a.txt": "one\n2\n3\n"},
+			wantReplayed: 0,
+			wantFiles:    map[string]string{"a.txt": "one\n2\n3\n"},
+		},
+		{
+			name:          "same line",
+			upstream:      map[string]string{"a.txt": "one\n2\n3\n"},
+			local:         map[string]string{"a.txt": "uno\n2\n3\n"},
+			wantErr:       errTestConflict,
+			wantConflicts: 1,
+		},
+		{
+			name:         "only behind",
+			upstream:     map[string]string{"a.txt": "one\n2\n3\n"},
+			wantReplayed: 0,
+			wantFiles:    map[string]string{"a.txt": "one\n2\n3\n"},
+		},
+		{
+			name:    "only ahead",
+			local:   map[string]string{"b.txt": "b2\n"},
+			wantErr: errTestUpToDate,
+		},
+		{
+			name:    "up to date",
+			wantErr: errTestUpToDate,
+		},
+		{
+			name:    "no remote branch",
+			local:   map[string]string{"b.txt": "b2\n"},
+			remote:  "gone",
+			wantErr: errTestNoUpstream,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, r := rebaseRepo(t, tt.upstream, tt.local)
+			branch := tt.remote
+			if branch == "" {
+				branch = "main"
+			}
+			state, err := g.Rebase(&model.Upstream{Remote: "origin", Branch: model.Branch(branch)}, "", nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rebase() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantConflicts > 0 {
+				if state == nil || len(state.Conflicts) != tt.wantConflicts {
+					t.Fatalf("Rebase() state = %+v, want %d conflicts", state, tt.wantConflicts)
+				}
+				saved, err := g.RebaseState()
+				if err != nil || saved == nil {
+					t.Fatalf("RebaseState() = %v, %v, want the stopped rebase", saved, err)
+				}
+				_, err = g.RebaseAbort()
+				if err != nil {
+					t.Fatal(err)
+				}
+				head, _ := r.Head()
+				if head.Hash().String() != state.OrigHead {
+					t.Errorf("RebaseAbort() left HEAD at %s, want %s", head.Hash(), state.OrigHead)
+				}
+				return
+			}
+			if err != nil {
+				return
+			}
+			if state.Replayed != tt.wantReplayed {
+				t.Errorf("Rebase() replayed %d commits, want %d", state.Replayed, tt.wantReplayed)
+			}
+			head, err := r.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if head.Hash().String() != state.Tip {
+				t.Errorf("HEAD = %s, want the rebased tip %s", head.Hash(), state.Tip)
+			}
+			for name, want := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(g.rootDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			state, err = g.RebaseState()
+			if err != nil || state != nil {
+				t.Errorf("RebaseState() = %+v, %v, want no rebase left", state, err)
+			}
+		})
+	}
+}
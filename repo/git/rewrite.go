@@ -0,0 +1,325 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// treeFile is a blob, symlink or submodule entry of a flattened tree.
+type treeFile struct {
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+type treeNode struct {
+	file     *treeFile
+	children map[string]*treeNode
+}
+
+// ResolveCommit looks up a commit by hash, short hash or revision such as HEAD~2.
+func (g *Git) ResolveCommit(rev string) (*model.Commit, error) {
+	c, err := g.commitObject(rev)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Commit{Hash: c.Hash.String(), Message: c.Message}, nil
+}
+
+func (g *Git) commitObject(rev string) (*object.Commit, error) {
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, g.err.CommitNotFound
+	}
+	return g.repo.CommitObject(*hash)
+}
+
+// IsPushed reports whether the commit at rev is reachable from the
+// remote-tracking ref of branch, as last fetched from remoteName.
+func (g *Git) IsPushed(remoteName string, branch model.Branch, rev string) (bool, error) {
+	ref, err := g.repo.Reference(plumbing.NewRemoteReferenceName(remoteName, branch.String()), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	tip, err := g.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return false, err
+	}
+	c, err := g.commitObject(rev)
+	if err != nil {
+		return false, err
+	}
+	if c.Hash == tip.Hash {
+		return true, nil
+	}
+	return c.IsAncestor(tip)
+}
+
+// Autosquash folds the HEAD commit into target, an ancestor of HEAD, and
+// replays the commits in between on top of it, as git rebase --autosquash
+// does for a fixup commit. It fails with AutosquashFailed rather than
+// resolving conflicts: when a commit in between is a merge or touches a file
+// HEAD changes. The working tree and index are left as they are, HEAD points
+// to a tree with the same content.
+func (g *Git) Autosquash(target string, committer *model.Identity) error {
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	if !head.Name().IsBranch() {
+		return g.err.AutosquashFailed
+	}
+	fixup, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	targetCommit, err := g.commitObject(target)
+	if err != nil {
+		return err
+	}
+	if len(fixup.ParentHashes) != 1 {
+		return g.err.AutosquashFailed
+	}
+
+	chain := []*object.Commit{}
+	c, err := fixup.Parent(0)
+	if err != nil {
+		return err
+	}
+	for c.Hash != targetCommit.Hash {
+		if len(c.ParentHashes) != 1 {
+			// a merge, or the root was reached without meeting target
+			return g.err.AutosquashFailed
+		}
+		chain = append(chain, c)
+		c, err = c.Parent(0)
+		if err != nil {
+			return err
+		}
+	}
+	slices.Reverse(chain)
+
+	changes, err := g.commitChanges(fixup)
+	if err != nil {
+		return err
+	}
+	for _, c := range chain {
+		touched, err := g.commitChanges(c)
+		if err != nil {
+			return err
+		}
+		for path := range touched {
+			if _, ok := changes[path]; ok {
+				return g.err.AutosquashFailed
+			}
+		}
+	}
+
+	now := time.Now()
+	committerSig := signature(committer, now)
+	if committerSig == nil {
+		committerSig = &object.Signature{Name: fixup.Committer.Name, Email: fixup.Committer.Email, When: now}
+	}
+	rewrite := func(c *object.Commit, parents []plumbing.Hash) (plumbing.Hash, error) {
+		tree, err := c.Tree()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		files, err := g.flattenTree(tree)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		treeHash, err := g.writeTree(applyChanges(files, changes))
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return g.writeCommit(&object.Commit{
+			Author:       c.Author,
+			Committer:    *committerSig,
+			Message:      c.Message,
+			TreeHash:     treeHash,
+			ParentHashes: parents,
+		})
+	}
+	hash, err := rewrite(targetCommit, targetCommit.ParentHashes)
+	if err != nil {
+		return err
+	}
+	for _, c := range chain {
+		hash, err = rewrite(c, []plumbing.Hash{hash})
+		if err != nil {
+			return err
+		}
+	}
+	return g.repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), hash))
+}
+
+// commitChanges maps the paths c changes compared to its first parent to
+// their new entry, nil for deleted paths.
+func (g *Git) commitChanges(c *object.Commit) (map[string]*treeFile, error) {
+	var parentTree *object.Tree
+	if len(c.ParentHashes) > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		parentTree, err = parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	before, err := g.flattenTree(parentTree)
+	if err != nil {
+		return nil, err
+	}
+	after, err := g.flattenTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	return changedFiles(before, after), nil
+}
+
+func changedFiles(before, after map[string]treeFile) map[string]*treeFile {
+	changes := map[string]*treeFile{}
+	for path, file := range after {
+		if old, ok := before[path]; !ok || old != file {
+			changes[path] = &file
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes[path] = nil
+		}
+	}
+	return changes
+}
+
+func applyChanges(files map[string]treeFile, changes map[string]*treeFile) map[string]treeFile {
+	out := make(map[string]treeFile, len(files))
+	for path, file := range files {
+		out[path] = file
+	}
+	for path, file := range changes {
+		if file == nil {
+			delete(out, path)
+		} else {
+			out[path] = *file
+		}
+	}
+	return out
+}
+
+// flattenTree maps the path of every file in tree to its entry, a nil tree is
+// empty.
+func (g *Git) flattenTree(tree *object.Tree) (map[string]treeFile, error) {
+	files := map[string]treeFile{}
+	if tree == nil {
+		return files, nil
+	}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode != filemode.Dir {
+			files[name] = treeFile{mode: entry.Mode, hash: entry.Hash}
+		}
+	}
+}
+
+// writeTree stores the nested trees holding files and returns the root hash.
+func (g *Git) writeTree(files map[string]treeFile) (plumbing.Hash, error) {
+	root := &treeNode{children: map[string]*treeNode{}}
+	for path, file := range files {
+		node := root
+		parts := strings.Split(path, "/")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node.children[part]
+			if !ok {
+				child = &treeNode{children: map[string]*treeNode{}}
+				node.children[part] = child
+			}
+			node = child
+		}
+		node.children[parts[len(parts)-1]] = &treeNode{file: &file}
+	}
+	return g.writeTreeNode(root)
+}
+
+func (g *Git) writeTreeNode(node *treeNode) (plumbing.Hash, error) {
+	entries := []object.TreeEntry{}
+	for name, child := range node.children {
+		if child.file != nil {
+			entries = append(entries, object.TreeEntry{Name: name, Mode: child.file.mode, Hash: child.file.hash})
+			continue
+		}
+		hash, err := g.writeTreeNode(child)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+	// git sorts directories as if their name ended with a slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortName(entries[i]) < sortName(entries[j])
+	})
+
+	obj := g.repo.Storer.NewEncodedObject()
+	err := (&object.Tree{Entries: entries}).Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return g.repo.Storer.SetEncodedObject(obj)
+}
+
+// writeCommit stores c, signed when a signer was set.
+func (g *Git) writeCommit(c *object.Commit) (plumbing.Hash, error) {
+	if g.signer != nil {
+		encoded := &plumbing.MemoryObject{}
+		err := c.EncodeWithoutSignature(encoded)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		r, err := encoded.Reader()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		sig, err := g.signer.Sign(r)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		c.PGPSignature = string(sig)
+	}
+	obj := g.repo.Storer.NewEncodedObject()
+	err := c.Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return g.repo.Storer.SetEncodedObject(obj)
+}
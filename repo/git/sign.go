@@ -0,0 +1,251 @@
+package git
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	gitCfg "github.com/go-git/go-git/v5/config"
+	"github.com/seriouspoop/gopush/model"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	sshSigMagic     = "SSHSIG"
+	sshSigVersion   = 1
+	sshSigNamespace = "git"
+	sshSigHash      = "sha512"
+	// sshSigLineWidth matches the armor ssh-keygen -Y sign writes.
+	sshSigLineWidth = 70
+)
+
+// SigningConfig reads commit.gpgsign, gpg.format and user.signingkey from the
+// repository, global and system git config.
+func (g *Git) SigningConfig() (*model.Signing, bool, error) {
+	cfg, err := g.repo.ConfigScoped(gitCfg.SystemScope)
+	if err != nil {
+		return nil, false, err
+	}
+	enabled := configBool(cfg.Raw.Section("commit").Option("gpgsign"))
+	sig := &model.Signing{Format: model.SignOpenPGP}
+	if format := cfg.Raw.Section("gpg").Option("format"); format != "" {
+		sig.Format = model.SignFormat(format)
+	}
+	key := cfg.Raw.Section("user").Option("signingkey")
+	switch sig.Format {
+	case model.SignSSH:
+		// git is given the public key, the private key sits next to it. Literal
+		// keys are left out as they only work through ssh-agent.
+		if key != "" && !strings.HasPrefix(key, "key::") && !strings.HasPrefix(key, "ssh-") {
+			sig.Key = strings.TrimSuffix(expandHome(key), ".pub")
+		}
+	default:
+		sig.KeyID = key
+	}
+	return sig, enabled, nil
+}
+
+func configBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(os.Getenv("HOME"), rest)
+	}
+	return path
+}
+
+// SetSigner loads the key used to sign commits. InvalidPassphrase is returned
+// when the key is encrypted and the passphrase is missing or wrong.
+func (g *Git) SetSigner(sig *model.Signing, passphrase string) error {
+	switch sig.Format {
+	case model.SignOpenPGP:
+		key, err := g.openPGPKey(sig, passphrase)
+		if err != nil {
+			return err
+		}
+		g.signKey, g.signer = key, &pgpSigner{key: key}
+	case model.SignSSH:
+		signer, err := g.sshKey(sig.Key, passphrase)
+		if err != nil {
+			return err
+		}
+		g.signKey, g.signer = nil, &sshSigner{signer: signer}
+	default:
+		return g.err.SignFormatNotSupported
+	}
+	return nil
+}
+
+func (g *Git) openPGPKey(sig *model.Signing, passphrase string) (*openpgp.Entity, error) {
+	f, err := os.Open(expandHome(sig.Key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, g.err.SignKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keyring, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *openpgp.Entity
+	for _, entity := range keyring {
+		if entity.PrivateKey != nil && (sig.KeyID == "" || matchKeyID(entity, sig.KeyID)) {
+			key = entity
+			break
+		}
+	}
+	if key == nil {
+		return nil, g.err.SignKeyNotFound
+	}
+	if !privateKeysEncrypted(key) {
+		return key, nil
+	}
+	if passphrase == "" || key.DecryptPrivateKeys([]byte(passphrase)) != nil {
+		return nil, g.err.InvalidPassphrase
+	}
+	return key, nil
+}
+
+// matchKeyID accepts the ids gpg accepts for user.signingkey: a fingerprint,
+// a long or short key id of the key or a subkey, or part of a user id.
+func matchKeyID(entity *openpgp.Entity, keyID string) bool {
+	id := strings.ToUpper(strings.TrimPrefix(strings.TrimSuffix(keyID, "!"), "0x"))
+	keys := []*openpgp.Subkey{{PublicKey: entity.PrimaryKey}}
+	for i := range entity.Subkeys {
+		keys = append(keys, &entity.Subkeys[i])
+	}
+	for _, k := range keys {
+		fingerprint := strings.ToUpper(hex.EncodeToString(k.PublicKey.Fingerprint))
+		if id == fingerprint || id == k.PublicKey.KeyIdString() || id == k.PublicKey.KeyIdShortString() {
+			return true
+		}
+	}
+	for name := range entity.Identities {
+		if strings.Contains(strings.ToLower(name), strings.ToLower(keyID)) {
+			return true
+		}
+	}
+	return false
+}
+
+func privateKeysEncrypted(entity *openpgp.Entity) bool {
+	if entity.PrivateKey.Encrypted {
+		return true
+	}
+	for _, sub := range entity.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Git) sshKey(path, passphrase string) (gossh.Signer, error) {
+	b, err := os.ReadFile(expandHome(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, g.err.SignKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	signer, err := gossh.ParsePrivateKey(b)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, g.err.InvalidPassphrase
+		}
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(b, []byte(passphrase))
+	}
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return nil, g.err.InvalidPassphrase
+	}
+	return signer, err
+}
+
+// pgpSigner signs objects with an armored detached OpenPGP signature, as
+// go-git does for CommitOptions.SignKey.
+type pgpSigner struct {
+	key *openpgp.Entity
+}
+
+func (s *pgpSigner) Sign(message io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	err := openpgp.ArmoredDetachSign(&b, s.key, message, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// sshSigner signs objects in the SSHSIG format git verifies for
+// gpg.format=ssh, the same signature ssh-keygen -Y sign -n git produces.
+type sshSigner struct {
+	signer gossh.Signer
+}
+
+func (s *sshSigner) Sign(message io.Reader) ([]byte, error) {
+	h := sha512.New()
+	_, err := io.Copy(h, message)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := bytes.NewBufferString(sshSigMagic)
+	writeSSHString(signed, []byte(sshSigNamespace))
+	writeSSHString(signed, nil)
+	writeSSHString(signed, []byte(sshSigHash))
+	writeSSHString(signed, h.Sum(nil))
+
+	var sig *gossh.Signature
+	algoSigner, ok := s.signer.(gossh.AlgorithmSigner)
+	if ok && s.signer.PublicKey().Type() == gossh.KeyAlgoRSA {
+		// ssh-rsa signatures use sha1 which git refuses to verify
+		sig, err = algoSigner.SignWithAlgorithm(rand.Reader, signed.Bytes(), gossh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = s.signer.Sign(rand.Reader, signed.Bytes())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blob := bytes.NewBufferString(sshSigMagic)
+	_ = binary.Write(blob, binary.BigEndian, uint32(sshSigVersion))
+	writeSSHString(blob, s.signer.PublicKey().Marshal())
+	writeSSHString(blob, []byte(sshSigNamespace))
+	writeSSHString(blob, nil)
+	writeSSHString(blob, []byte(sshSigHash))
+	writeSSHString(blob, gossh.Marshal(sig))
+
+	encoded := base64.StdEncoding.EncodeToString(blob.Bytes())
+	var armor strings.Builder
+	armor.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > sshSigLineWidth {
+		armor.WriteString(encoded[:sshSigLineWidth] + "\n")
+		encoded = encoded[sshSigLineWidth:]
+	}
+	armor.WriteString(encoded + "\n")
+	armor.WriteString("-----END SSH SIGNATURE-----\n")
+	return []byte(armor.String()), nil
+}
+
+func writeSSHString(buf *bytes.Buffer, b []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	buf.Write(b)
+}
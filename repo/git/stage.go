@@ -0,0 +1,303 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// hunkContext is the number of unchanged lines shown around a hunk.
+const hunkContext = 3
+
+// Add stages the given paths, deleted paths are removed from the index.
+func (g *Git) Add(paths []string) error {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		_, err = w.Add(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unstage resets the index entry of each path to its HEAD version, paths not
+// in HEAD are dropped from the index. The worktree is left untouched.
+func (g *Git) Unstage(paths []string) error {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	tree, err := g.headTree()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		var f *object.File
+		if tree != nil {
+			f, _ = tree.File(path)
+		}
+		if f == nil {
+			idx.Remove(path)
+			continue
+		}
+		e, err := idx.Entry(path)
+		if errors.Is(err, index.ErrEntryNotFound) {
+			e = idx.Add(path)
+		} else if err != nil {
+			return err
+		}
+		e.Hash = f.Hash
+		e.Mode = f.Mode
+		e.Size = 0
+	}
+	return g.repo.Storer.SetIndex(idx)
+}
+
+func (g *Git) readBlob(hash plumbing.Hash) ([]byte, error) {
+	blob, err := g.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (g *Git) writeBlobObject(content []byte) (plumbing.Hash, error) {
+	obj := g.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	_, err = w.Write(content)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	err = w.Close()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return g.repo.Storer.SetEncodedObject(obj)
+}
+
+func isBinary(b []byte) bool {
+	if len(b) > 8000 {
+		b = b[:8000]
+	}
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// StageHunks asks accept about every hunk between the staged and the worktree
+// version of path and stages only the accepted ones. New, deleted and binary
+// files are offered as a single whole file hunk.
+func (g *Git) StageHunks(path string, accept func(*model.Hunk) (bool, error)) error {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	entry, entryErr := idx.Entry(path)
+	if entryErr != nil && !errors.Is(entryErr, index.ErrEntryNotFound) {
+		return entryErr
+	}
+	current, readErr := os.ReadFile(filepath.Join(g.rootDir, filepath.FromSlash(path)))
+	if readErr != nil && !errors.Is(readErr, os.ErrNotExist) {
+		return readErr
+	}
+
+	var staged []byte
+	if entryErr == nil {
+		staged, err = g.readBlob(entry.Hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	if entryErr != nil || readErr != nil || isBinary(staged) || isBinary(current) {
+		ok, err := accept(&model.Hunk{Path: path, Whole: true})
+		if err != nil || !ok {
+			return err
+		}
+		return g.Add([]string{path})
+	}
+
+	diffs := diff.Do(string(staged), string(current))
+	var result strings.Builder
+	for i := 0; i < len(diffs); {
+		if diffs[i].Type == diffmatchpatch.DiffEqual {
+			result.WriteString(diffs[i].Text)
+			i++
+			continue
+		}
+
+		hunk := &model.Hunk{Path: path}
+		if i > 0 {
+			before := splitLines(diffs[i-1].Text)
+			hunk.Before = before[max(0, len(before)-hunkContext):]
+		}
+		var removed, added strings.Builder
+		for ; i < len(diffs) && diffs[i].Type != diffmatchpatch.DiffEqual; i++ {
+			if diffs[i].Type == diffmatchpatch.DiffDelete {
+				removed.WriteString(diffs[i].Text)
+			} else {
+				added.WriteString(diffs[i].Text)
+			}
+		}
+		if i < len(diffs) {
+			after := splitLines(diffs[i].Text)
+			hunk.After = after[:min(len(after), hunkContext)]
+		}
+		hunk.Removed = splitLines(removed.String())
+		hunk.Added = splitLines(added.String())
+
+		ok, err := accept(hunk)
+		if err != nil {
+			return err
+		}
+		if ok {
+			result.WriteString(added.String())
+		} else {
+			result.WriteString(removed.String())
+		}
+	}
+
+	content := []byte(result.String())
+	if bytes.Equal(content, staged) {
+		return nil
+	}
+	hash, err := g.writeBlobObject(content)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	entry.Size = uint32(len(content))
+	return g.repo.Storer.SetIndex(idx)
+}
+
+// headTree returns the tree HEAD points to, nil on a repo without commits.
+func (g *Git) headTree() (*object.Tree, error) {
+	head, err := g.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	commit, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// StagedAdditions returns the lines every staged text file adds compared to
+// HEAD, binary files are skipped.
+func (g *Git) StagedAdditions() ([]*model.FileDiff, error) {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := g.headTree()
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := []*model.FileDiff{}
+	for _, e := range idx.Entries {
+		if e.Stage != 0 || e.Mode == filemode.Submodule {
+			continue
+		}
+		var old []byte
+		if tree != nil {
+			if f, err := tree.File(e.Name); err == nil {
+				if f.Hash == e.Hash {
+					continue
+				}
+				old, err = g.readBlob(f.Hash)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		staged, err := g.readBlob(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if isBinary(old) || isBinary(staged) {
+			continue
+		}
+
+		fd := &model.FileDiff{Path: e.Name}
+		line := 0
+		for _, d := range diff.Do(string(old), string(staged)) {
+			if d.Type == diffmatchpatch.DiffDelete {
+				continue
+			}
+			for _, l := range splitLines(d.Text) {
+				line++
+				if d.Type == diffmatchpatch.DiffInsert {
+					fd.Added = append(fd.Added, model.AddedLine{Number: line, Text: strings.TrimSuffix(l, "\n")})
+				}
+			}
+		}
+		if len(fd.Added) > 0 {
+			diffs = append(diffs, fd)
+		}
+	}
+	return diffs, nil
+}
+
+// LFSTracked reports which of paths fall under a "filter=lfs" rule of the
+// repo .gitattributes files.
+func (g *Git) LFSTracked(paths []string) (map[string]bool, error) {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := gitattributes.ReadPatterns(w.Filesystem, nil)
+	if err != nil {
+		return nil, err
+	}
+	matcher := gitattributes.NewMatcher(patterns)
+
+	tracked := map[string]bool{}
+	for _, path := range paths {
+		attrs, ok := matcher.Match(strings.Split(path, "/"), []string{"filter"})
+		if !ok {
+			continue
+		}
+		if filter, ok := attrs["filter"]; ok && filter.Value() == "lfs" {
+			tracked[path] = true
+		}
+	}
+	return tracked, nil
+}
@@ -0,0 +1,238 @@
+package git
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// autostashRef keeps the stashed commit reachable until it is restored.
+const autostashRef = plumbing.ReferenceName("refs/gopush/autostash")
+
+// Stash records the changes to tracked files, staged or not, in a commit on
+// top of HEAD and resets the working tree to HEAD. Like git stash, the index
+// is recorded in a second parent on top of HEAD. Untracked files are left in
+// place. It returns the stash commit, empty when there was nothing to stash.
+func (g *Git) Stash(committer *model.Identity) (string, error) {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return "", err
+	}
+	changes := map[string]*treeFile{}
+	for path, s := range status {
+		if s.Worktree == git.Untracked || (s.Worktree == git.Unmodified && s.Staging == git.Unmodified) {
+			continue
+		}
+		file, err := g.readWorktreeFile(path)
+		if err != nil {
+			return "", err
+		}
+		changes[path] = file
+	}
+	if len(changes) == 0 {
+		return "", nil
+	}
+
+	head, err := g.commitObject("HEAD")
+	if err != nil {
+		return "", err
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return "", err
+	}
+	files, err := g.flattenTree(tree)
+	if err != nil {
+		return "", err
+	}
+	treeHash, err := g.writeTree(applyChanges(files, changes))
+	if err != nil {
+		return "", err
+	}
+	staged, err := g.indexFiles()
+	if err != nil {
+		return "", err
+	}
+	indexTreeHash, err := g.writeTree(staged)
+	if err != nil {
+		return "", err
+	}
+	sig := signature(committer, time.Now())
+	if sig == nil {
+		sig = &object.Signature{Name: head.Committer.Name, Email: head.Committer.Email, When: time.Now()}
+	}
+	indexHash, err := g.writeStashCommit(&object.Commit{
+		Author:       *sig,
+		Committer:    *sig,
+		Message:      "gopush autostash index\n",
+		TreeHash:     indexTreeHash,
+		ParentHashes: []plumbing.Hash{head.Hash},
+	})
+	if err != nil {
+		return "", err
+	}
+	hash, err := g.writeStashCommit(&object.Commit{
+		Author:       *sig,
+		Committer:    *sig,
+		Message:      "gopush autostash\n",
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash, indexHash},
+	})
+	if err != nil {
+		return "", err
+	}
+	err = g.repo.Storer.SetReference(plumbing.NewHashReference(autostashRef, hash))
+	if err != nil {
+		return "", err
+	}
+	err = g.resetHard(head.Hash)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// writeStashCommit stores c unsigned, stash commits are never pushed.
+func (g *Git) writeStashCommit(c *object.Commit) (plumbing.Hash, error) {
+	obj := g.repo.Storer.NewEncodedObject()
+	err := c.Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return g.repo.Storer.SetEncodedObject(obj)
+}
+
+// Unstash applies the changes of the stash commit to the working tree,
+// merging them with what HEAD changed since the stash was taken, and stages
+// again what was staged. Files that could not be merged are written with
+// conflict markers and returned, the stash is then kept under
+// refs/gopush/autostash.
+func (g *Git) Unstash(stash string) ([]*model.Conflict, error) {
+	c, err := g.repo.CommitObject(plumbing.NewHash(stash))
+	if err != nil {
+		return nil, g.err.CommitNotFound
+	}
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := g.commitChanges(c)
+	if err != nil {
+		return nil, err
+	}
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return nil, err
+	}
+	base, err := g.flattenTree(parentTree)
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := g.headTree()
+	if err != nil {
+		return nil, err
+	}
+	ours, err := g.flattenTree(headTree)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []*model.Conflict{}
+	for path, theirs := range changes {
+		merged, kind, err := g.mergeFile(lookup(base, path), lookup(ours, path), theirs, "HEAD", "autostash")
+		if err != nil {
+			return nil, err
+		}
+		if kind != "" {
+			conflicts = append(conflicts, &model.Conflict{Path: path, Kind: kind})
+		}
+		err = g.writeWorktreeFile(path, merged)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = g.unstashIndex(c, base, ours)
+	if err != nil {
+		return nil, err
+	}
+	sortConflicts(conflicts)
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+	err = g.repo.Storer.RemoveReference(autostashRef)
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// unstashIndex stages the changes recorded in the index commit of the stash
+// c, its second parent, merged with HEAD. Paths that do not merge cleanly
+// are left unstaged, their conflicts are reported with the working tree.
+func (g *Git) unstashIndex(c *object.Commit, base, ours map[string]treeFile) error {
+	if c.NumParents() < 2 {
+		return nil
+	}
+	indexCommit, err := c.Parent(1)
+	if err != nil {
+		return err
+	}
+	tree, err := indexCommit.Tree()
+	if err != nil {
+		return err
+	}
+	staged, err := g.flattenTree(tree)
+	if err != nil {
+		return err
+	}
+	changes := changedFiles(base, staged)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for path, theirs := range changes {
+		merged, kind, err := g.mergeFile(lookup(base, path), lookup(ours, path), theirs, "HEAD", "autostash")
+		if err != nil {
+			return err
+		}
+		if kind != "" {
+			continue
+		}
+		if merged == nil {
+			idx.Remove(path)
+			continue
+		}
+		e, err := idx.Entry(path)
+		if errors.Is(err, index.ErrEntryNotFound) {
+			e = idx.Add(path)
+		} else if err != nil {
+			return err
+		}
+		e.Hash = merged.hash
+		e.Mode = merged.mode
+		e.Size = 0
+	}
+	return g.repo.Storer.SetIndex(idx)
+}
+
+// lookup returns the entry of path in files, nil when it is missing.
+func lookup(files map[string]treeFile, path string) *treeFile {
+	file, ok := files[path]
+	if !ok {
+		return nil
+	}
+	return &file
+}
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestStash(t *testing.T) {
+	tests := []struct {
+		name      string
+		staged    map[string]string
+		unstaged  map[string]string
+		head      map[string]string
+		wantFiles map[string]string
+		// wantStatus is the staging and worktree code of each changed file
+		wantStatus map[string]string
+	}{
+		{
+			name:       "staged and unstaged files",
+			staged:     map[string]string{"a.txt": "1\n2\nthree\n"},
+			unstaged:   map[string]string{"b.txt": "b2\n"},
+			wantFiles:  map[string]string{"a.txt": "1\n2\nthree\n", "b.txt": "b2\n"},
+			wantStatus: map[string]string{"a.txt": "M ", "b.txt": " M"},
+		},
+		{
+			name:       "file edited after staging",
+			staged:     map[string]string{"a.txt": "1\n2\nthree\n"},
+			unstaged:   map[string]string{"a.txt": "1\n2\nTHREE\n"},
+			wantFiles:  map[string]string{"a.txt": "1\n2\nTHREE\n"},
+			wantStatus: map[string]string{"a.txt": "MM"},
+		},
+		{
+			name:       "head changed other lines",
+			staged:     map[string]string{"a.txt": "1\n2\nthree\n"},
+			head:       map[string]string{"a.txt": "one\n2\n3\n"},
+			wantFiles:  map[string]string{"a.txt": "one\n2\nthree\n"},
+			wantStatus: map[string]string{"a.txt": "M "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, r := rebaseRepo(t, nil, nil)
+			wt, err := r.Worktree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			write := func(files map[string]string, stage bool) {
+				for name, content := range files {
+					err := os.WriteFile(filepath.Join(g.rootDir, name), []byte(content), 0644)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if stage {
+						_, err = wt.Add(name)
+						if err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+			}
+			write(tt.staged, true)
+			write(tt.unstaged, false)
+
+			stash, err := g.Stash(nil)
+			if err != nil || stash == "" {
+				t.Fatalf("Stash() = %q, %v, want a stash commit", stash, err)
+			}
+			status, err := wt.Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !status.IsClean() {
+				t.Fatalf("Stash() left changes:\n%s", status)
+			}
+			if tt.head != nil {
+				write(tt.head, true)
+				sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+				_, err = wt.Commit("head", &git.CommitOptions{Author: sig, Committer: sig})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			conflicts, err := g.Unstash(stash)
+			if err != nil || len(conflicts) > 0 {
+				t.Fatalf("Unstash() = %v, %v, want no conflicts", conflicts, err)
+			}
+			for name, want := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(g.rootDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			status, err = wt.Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.wantStatus {
+				s := status.File(name)
+				if got := string([]byte{byte(s.Staging), byte(s.Worktree)}); got != want {
+					t.Errorf("status of %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// State files live in the git directory, like git's own rebase-merge.
+const (
+	rebaseStateFile = "gopush/rebase.json"
+	checkpointFile  = "gopush/checkpoint.json"
+)
+
+func (g *Git) gitPath(name string) string {
+	return filepath.Join(g.rootDir, git.GitDirName, filepath.FromSlash(name))
+}
+
+// readState decodes the state file name into v, ok is false when it is missing.
+func (g *Git) readState(name string, v any) (ok bool, err error) {
+	data, err := os.ReadFile(g.gitPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (g *Git) writeState(name string, v any) error {
+	path := g.gitPath(name)
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (g *Git) removeState(name string) error {
+	err := os.Remove(g.gitPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// RebaseState returns the stopped rebase, nil when none is in progress.
+func (g *Git) RebaseState() (*model.Rebase, error) {
+	state := &model.Rebase{}
+	ok, err := g.readState(rebaseStateFile, state)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Checkpoint returns the position saved before a run stopped, nil when none
+// was saved.
+func (g *Git) Checkpoint() (*model.Checkpoint, error) {
+	cp := &model.Checkpoint{}
+	ok, err := g.readState(checkpointFile, cp)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (g *Git) SaveCheckpoint(cp *model.Checkpoint) error {
+	return g.writeState(checkpointFile, cp)
+}
+
+func (g *Git) RemoveCheckpoint() error {
+	return g.removeState(checkpointFile)
+}
+
+// RestoreCheckpoint checks out the branch of cp and moves it back to cp.Head.
+// The working tree is kept, so changes committed since show up as unstaged.
+func (g *Git) RestoreCheckpoint(cp *model.Checkpoint) error {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(cp.Branch.String())
+	if head.Name() != branch {
+		err = w.Checkout(&git.CheckoutOptions{Branch: branch, Keep: true})
+		if err != nil {
+			return err
+		}
+	}
+	return w.Reset(&git.ResetOptions{Commit: plumbing.NewHash(cp.Head), Mode: git.MixedReset})
+}
+
+// SaveIndex keeps a copy of the index in memory for RestoreIndex and
+// RollbackRun.
+func (g *Git) SaveIndex() error {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	g.savedIndex = idx
+	return nil
+}
+
+// RestoreIndex puts back the index saved by SaveIndex, staging again what was
+// staged then and nothing else. It does nothing when no index was saved.
+func (g *Git) RestoreIndex() error {
+	if g.savedIndex == nil {
+		return nil
+	}
+	err := g.repo.Storer.SetIndex(g.savedIndex)
+	if err != nil {
+		return err
+	}
+	g.savedIndex = nil
+	return nil
+}
+
+// revertSince writes the files HEAD changed since the commit hash back to the
+// working tree and the index as they were at hash, leaving out the files
+// with local changes.
+func (g *Git) revertSince(hash plumbing.Hash) error {
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	if head.Hash() == hash {
+		return nil
+	}
+	headCommit, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	since, err := g.repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	headFiles, err := g.commitFiles(headCommit)
+	if err != nil {
+		return err
+	}
+	sinceFiles, err := g.commitFiles(since)
+	if err != nil {
+		return err
+	}
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+	changes := changedFiles(headFiles, sinceFiles)
+	for path := range changes {
+		if s, ok := status[path]; ok && (s.Worktree != git.Unmodified || s.Staging != git.Unmodified) {
+			delete(changes, path)
+		}
+	}
+	return g.checkoutChanges(changes)
+}
+
+// RollbackRun moves the branch of cp back to cp.Head like RestoreCheckpoint.
+// The files changed since cp.Stopped, those a pull brought in, first get
+// their content at cp.Stopped back unless they were edited since, so only
+// the changes committed by the run are left in the working tree. The index
+// saved by SaveIndex is then put back, staging what was staged before.
+func (g *Git) RollbackRun(cp *model.Checkpoint) error {
+	if cp.Stopped != "" {
+		err := g.revertSince(plumbing.NewHash(cp.Stopped))
+		if err != nil {
+			return err
+		}
+	}
+	err := g.RestoreCheckpoint(cp)
+	if err != nil {
+		return err
+	}
+	return g.RestoreIndex()
+}
@@ -0,0 +1,141 @@
+package git
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// Tags lists the tags of the repository that point to commits.
+func (g *Git) Tags() ([]*model.Tag, error) {
+	iter, err := g.repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	tags := []*model.Tag{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tag := &model.Tag{Name: ref.Name().Short()}
+		hash := ref.Hash()
+		annotated, err := g.repo.TagObject(hash)
+		if err == nil {
+			if annotated.TargetType != plumbing.CommitObject {
+				return nil
+			}
+			tag.Date = annotated.Tagger.When
+			hash = annotated.Target
+		} else if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return err
+		}
+		c, err := g.repo.CommitObject(hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			// lightweight tags of trees or blobs
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if tag.Date.IsZero() {
+			tag.Date = c.Committer.When
+		}
+		tag.Commit = c.Hash.String()
+		tags = append(tags, tag)
+		return nil
+	})
+	return tags, err
+}
+
+// CommitsBetween lists the commits reachable from to but not from from,
+// newest first. An empty from lists the whole history of to.
+func (g *Git) CommitsBetween(from, to string) ([]*model.Commit, error) {
+	end, err := g.commitObject(to)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[plumbing.Hash]bool{}
+	if from != "" {
+		start, err := g.commitObject(from)
+		if err != nil {
+			return nil, err
+		}
+		seen, err = g.ancestors(start.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	iter, err := g.repo.Log(&git.LogOptions{From: end.Hash, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	commits := []*model.Commit{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if !seen[c.Hash] {
+			commits = append(commits, &model.Commit{Hash: c.Hash.String(), Message: c.Message})
+		}
+		return nil
+	})
+	return commits, err
+}
+
+// CreateTag points the annotated tag name at the commit rev, signed when a
+// signer was set. It fails with TagExists when the tag is already there.
+func (g *Git) CreateTag(name, rev, message string, tagger *model.Identity) error {
+	_, err := g.repo.Tag(name)
+	if err == nil {
+		return g.err.TagExists
+	}
+	if !errors.Is(err, git.ErrTagNotFound) {
+		return err
+	}
+	target, err := g.commitObject(rev)
+	if err != nil {
+		return err
+	}
+	sig := signature(tagger, time.Now())
+	if sig == nil {
+		sig = &object.Signature{Name: target.Committer.Name, Email: target.Committer.Email, When: time.Now()}
+	}
+	tag := &object.Tag{
+		Name:       name,
+		Tagger:     *sig,
+		Message:    message,
+		TargetType: plumbing.CommitObject,
+		Target:     target.Hash,
+	}
+	if g.signer != nil {
+		encoded := &plumbing.MemoryObject{}
+		err := tag.EncodeWithoutSignature(encoded)
+		if err != nil {
+			return err
+		}
+		r, err := encoded.Reader()
+		if err != nil {
+			return err
+		}
+		signed, err := g.signer.Sign(r)
+		if err != nil {
+			return err
+		}
+		tag.PGPSignature = string(signed)
+	}
+	obj := g.repo.Storer.NewEncodedObject()
+	err = tag.Encode(obj)
+	if err != nil {
+		return err
+	}
+	hash, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+	return g.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName(name), hash))
+}
+
+// DeleteTag removes the tag name, as git tag -d does.
+func (g *Git) DeleteTag(name string) error {
+	return g.repo.DeleteTag(name)
+}
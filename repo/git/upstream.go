@@ -0,0 +1,233 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitCfg "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/seriouspoop/gopush/config"
+	"github.com/seriouspoop/gopush/model"
+)
+
+// Upstream reads the remote branch that branch tracks, nil when it tracks none.
+func (g *Git) Upstream(branch model.Branch) (*model.Upstream, error) {
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := cfg.Branches[branch.String()]
+	if !ok || b.Remote == "" || !b.Merge.IsBranch() {
+		return nil, nil
+	}
+	return &model.Upstream{
+		Remote: b.Remote,
+		Branch: model.Branch(b.Merge.Short()),
+	}, nil
+}
+
+// SetUpstream writes branch.<name>.remote and branch.<name>.merge so branch
+// tracks upstream.
+func (g *Git) SetUpstream(branch model.Branch, upstream *model.Upstream) error {
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return err
+	}
+	b, ok := cfg.Branches[branch.String()]
+	if !ok {
+		b = &gitCfg.Branch{Name: branch.String()}
+		cfg.Branches[branch.String()] = b
+	}
+	b.Remote = upstream.Remote
+	b.Merge = plumbing.NewBranchReferenceName(upstream.Branch.String())
+	return g.repo.SetConfig(cfg)
+}
+
+// RemoteHead returns the branch HEAD points to on remote. It is read from
+// refs/remotes/<remote>/HEAD, as git clone leaves it, or else asked to the
+// remote and remembered there. An empty remote has no HEAD and fails with
+// RemoteBranchNotFound.
+func (g *Git) RemoteHead(ctx context.Context, remote *model.Remote, auth *config.Credentials) (model.Branch, error) {
+	name := plumbing.NewRemoteHEADReferenceName(remote.Name)
+	prefix := fmt.Sprintf("refs/remotes/%s/", remote.Name)
+	ref, err := g.repo.Storer.Reference(name)
+	if err == nil && ref.Type() == plumbing.SymbolicReference && strings.HasPrefix(ref.Target().String(), prefix) {
+		return model.Branch(strings.TrimPrefix(ref.Target().String(), prefix)), nil
+	}
+
+	if auth == nil {
+		return "", g.err.AuthNotFound
+	}
+	if g.remote == nil {
+		return "", g.err.RemoteNotLoaded
+	}
+	Auth, err := g.authMethod(remote, auth)
+	if err != nil {
+		return "", err
+	}
+	refs, err := g.remote.ListContext(ctx, &git.ListOptions{Auth: Auth})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return "", g.err.KeyNotSupported
+		}
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return "", g.err.RemoteBranchNotFound
+		}
+		return "", fmt.Errorf("%w: %v", g.err.PullFailed, err)
+	}
+	for _, ref := range refs {
+		if ref.Name() != plumbing.HEAD || ref.Type() != plumbing.SymbolicReference || !ref.Target().IsBranch() {
+			continue
+		}
+		branch := ref.Target().Short()
+		err = g.repo.Storer.SetReference(plumbing.NewSymbolicReference(name, plumbing.NewRemoteReferenceName(remote.Name, branch)))
+		if err != nil {
+			return "", err
+		}
+		return model.Branch(branch), nil
+	}
+	return "", g.err.RemoteBranchNotFound
+}
+
+// AheadBehind counts the commits of branch missing from the remote-tracking
+// ref of upstream, and the other way round, as of the last fetch.
+func (g *Git) AheadBehind(branch model.Branch, upstream *model.Upstream) (ahead, behind int, err error) {
+	local, err := g.repo.Reference(plumbing.NewBranchReferenceName(branch.String()), true)
+	if err != nil {
+		return 0, 0, err
+	}
+	remote, err := g.repo.Reference(plumbing.NewRemoteReferenceName(upstream.Remote, upstream.Branch.String()), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return 0, 0, g.err.RemoteBranchNotFound
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	localCommit, err := g.repo.CommitObject(local.Hash())
+	if err != nil {
+		return 0, 0, err
+	}
+	remoteCommit, err := g.repo.CommitObject(remote.Hash())
+	if err != nil {
+		return 0, 0, err
+	}
+	bases, err := localCommit.MergeBase(remoteCommit)
+	if err != nil {
+		return 0, 0, err
+	}
+	// both sides are walked down to where they meet, not to the root
+	stop := map[plumbing.Hash]bool{}
+	for _, base := range bases {
+		stop[base.Hash] = true
+	}
+	ahead, err = countCommits(localCommit, stop)
+	if err != nil {
+		return 0, 0, err
+	}
+	behind, err = countCommits(remoteCommit, stop)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// countCommits counts the commits reachable from c without going through the
+// commits of stop.
+func countCommits(c *object.Commit, stop map[plumbing.Hash]bool) (int, error) {
+	n := 0
+	iter := object.NewCommitPreorderIter(c, stop, nil)
+	defer iter.Close()
+	err := iter.ForEach(func(*object.Commit) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// UnpushedMerges returns the merge commits reachable from HEAD that the
+// remote-tracking refs of branches on remote do not have yet. HEAD is walked
+// only down to where it meets those refs. Branches never fetched are skipped.
+func (g *Git) UnpushedMerges(remote string, branches []model.Branch) ([]*model.Commit, error) {
+	head, err := g.commitObject("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	tips := []*object.Commit{}
+	stop := map[plumbing.Hash]bool{}
+	for _, branch := range branches {
+		ref, err := g.repo.Reference(plumbing.NewRemoteReferenceName(remote, branch.String()), true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tip, err := g.repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, err
+		}
+		bases, err := head.MergeBase(tip)
+		if err != nil {
+			return nil, err
+		}
+		for _, base := range bases {
+			stop[base.Hash] = true
+		}
+		tips = append(tips, tip)
+	}
+
+	candidates := []*object.Commit{}
+	iter := object.NewCommitPreorderIter(head, stop, nil)
+	defer iter.Close()
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.NumParents() > 1 {
+			candidates = append(candidates, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// a side branch can lead past the merge bases into pushed history
+	merges := []*model.Commit{}
+	for _, c := range candidates {
+		pushed := false
+		for _, tip := range tips {
+			pushed, err = c.IsAncestor(tip)
+			if err != nil {
+				return nil, err
+			}
+			if pushed {
+				break
+			}
+		}
+		if !pushed {
+			merges = append(merges, &model.Commit{Hash: c.Hash.String(), Message: c.Message})
+		}
+	}
+	return merges, nil
+}
+
+// ancestors returns the set of commits reachable from hash, itself included.
+func (g *Git) ancestors(hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	seen := map[plumbing.Hash]bool{}
+	c, err := g.repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	defer iter.Close()
+	err = iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	return seen, err
+}
@@ -1,14 +1,23 @@
 package script
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/seriouspoop/gopush/model"
 )
 
+// waitDelay bounds how long a cancelled command may take to release its
+// output pipes before Wait gives up on it.
+const waitDelay = 5 * time.Second
+
 type Error struct {
 	FileNotExists error
 }
@@ -23,8 +32,29 @@ func New(svcError *Error) *Bash {
 	}
 }
 
-func (b *Bash) GetCurrentBranch() (model.Branch, error) {
-	cmd := exec.Command("git", "branch", "--show-current")
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// runTee runs cmd and returns its combined output, copying it to stream as it
+// is produced when stream is not nil.
+func runTee(cmd *exec.Cmd, stream io.Writer) (string, error) {
+	var output bytes.Buffer
+	w := io.Writer(&output)
+	if stream != nil {
+		w = io.MultiWriter(&output, stream)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err := cmd.Run()
+	return string(bytes.TrimSuffix(output.Bytes(), []byte("\n"))), err
+}
+
+func (b *Bash) GetCurrentBranch(ctx context.Context) (model.Branch, error) {
+	cmd := command(ctx, "git", "branch", "--show-current")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return model.Branch(""), err
@@ -32,14 +62,18 @@ func (b *Bash) GetCurrentBranch() (model.Branch, error) {
 	return model.Branch(string(output[:len(output)-1])), nil
 }
 
-func (b *Bash) GenerateMocks() (string, error) {
-	cmd := exec.Command("go", "generate", "./...")
-	output, err := cmd.CombinedOutput()
-	return string(output), err
+func (b *Bash) GenerateMocks(ctx context.Context, dir string, packages []string, stream io.Writer) (string, error) {
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	cmd := command(ctx, "go", append([]string{"generate"}, packages...)...)
+	cmd.Dir = dir
+	return runTee(cmd, stream)
 }
 
-func (b *Bash) TestsPresent() (bool, error) {
-	cmd := exec.Command("find", ".", "-name", "*.test.go", "-or", "-name", "*_test.go")
+func (b *Bash) TestsPresent(ctx context.Context, dir string) (bool, error) {
+	cmd := command(ctx, "find", ".", "-name", "*.test.go", "-or", "-name", "*_test.go")
+	cmd.Dir = dir
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return false, err
@@ -47,10 +81,10 @@ func (b *Bash) TestsPresent() (bool, error) {
 	return len(output) > 0, err
 }
 
-func (b *Bash) RunTests() (string, error) {
-	cmd := exec.Command("go", "test", "./...")
-	output, err := cmd.CombinedOutput()
-	return string(output[:len(output)-1]), err
+func (b *Bash) RunTests(ctx context.Context, dir string, stream io.Writer) (string, error) {
+	cmd := command(ctx, "go", "test", "./...")
+	cmd.Dir = dir
+	return runTee(cmd, stream)
 }
 
 func (b *Bash) Exists(path, name string) bool {
@@ -69,16 +103,42 @@ func (b *Bash) CreateDir(path, name string) error {
 	return os.Mkdir(dpath, os.ModePerm)
 }
 
-func (b *Bash) GenerateSSHKey(path, keyName, mail, passphrase string) error {
+func (b *Bash) GenerateSSHKey(ctx context.Context, path, keyName, mail, passphrase string) error {
 	filePath := filepath.Join(path, keyName)
-	cmd := exec.Command("ssh-keygen", "-t", "ed25519", "-C", mail, "-f", filePath, "-P", passphrase)
+	cmd := command(ctx, "ssh-keygen", "-t", "ed25519", "-C", mail, "-f", filePath, "-P", passphrase)
 	_, err := cmd.CombinedOutput()
 	return err
 }
 
-// TODO -> migrate this to go-git
-func (b *Bash) PullMerge() (string, error) {
-	cmd := exec.Command("git", "merge")
-	output, err := cmd.CombinedOutput()
-	return string(output[:len(output)-1]), err
+// LFSTrack adds path to the git lfs patterns in .gitattributes.
+func (b *Bash) LFSTrack(ctx context.Context, path string) (string, error) {
+	cmd := command(ctx, "git", "lfs", "track", "--filename", "--", path)
+	return runTee(cmd, nil)
+}
+
+// GitAdd stages paths with the git binary so clean filters such as git lfs
+// are applied, which go-git does not support.
+func (b *Bash) GitAdd(ctx context.Context, paths ...string) (string, error) {
+	cmd := command(ctx, "git", append([]string{"add", "--"}, paths...)...)
+	return runTee(cmd, nil)
+}
+
+// OpenEditor opens path in $VISUAL or $EDITOR, falling back to vi, attached to
+// the terminal. Unlike other commands it stays in the foreground process group
+// so the editor can read from the terminal.
+func (b *Bash) OpenEditor(ctx context.Context, path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// editors are often set with flags, e.g. "code --wait"
+	args := strings.Fields(editor)
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
 }
@@ -0,0 +1,9 @@
+//go:build windows
+
+package script
+
+import "os/exec"
+
+// setProcessGroup is a no-op on windows, exec.CommandContext already kills
+// the process on cancel.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -41,15 +41,82 @@ func Prompt(password bool, allowEmpty bool, label string, opts ...interface{}) (
 	return
 }
 
-func Select(items []string) (res string, err error) {
+func Select(label string, items []string) (res string, err error) {
 	selectTemplate := &promptui.SelectTemplates{
 		Active: "\U0001F892 {{ . | green }}",
 	}
 	prompt := promptui.Select{
-		Label:     "Select commit type",
+		Label:     label,
 		Items:     items,
 		Templates: selectTemplate,
 	}
 	_, res, err = prompt.Run()
 	return
 }
+
+// Confirm asks a yes/no question, answering no is not an error.
+func Confirm(label string, opts ...interface{}) (bool, error) {
+	if len(opts) > 0 {
+		label = fmt.Sprintf(label, opts...)
+	}
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	_, err := prompt.Run()
+	if errors.Is(err, promptui.ErrAbort) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MultiSelect lets the user toggle items on and off until "done" is picked.
+// selected holds the initial state and is updated in place.
+func MultiSelect(label string, items []string, selected []bool) error {
+	selectTemplate := &promptui.SelectTemplates{
+		Active: "\U0001F892 {{ . | green }}",
+	}
+	const (
+		doneRow   = "\U00002714 done"
+		toggleRow = "toggle all"
+	)
+	cursor, scroll := 0, 0
+	for {
+		rows := make([]string, 0, len(items)+2)
+		for i, item := range items {
+			mark := "[ ]"
+			if selected[i] {
+				mark = "[x]"
+			}
+			rows = append(rows, fmt.Sprintf("%s %s", mark, item))
+		}
+		rows = append(rows, toggleRow, doneRow)
+
+		prompt := promptui.Select{
+			Label:     label,
+			Items:     rows,
+			Templates: selectTemplate,
+			Size:      15,
+			HideHelp:  true,
+		}
+		idx, _, err := prompt.RunCursorAt(cursor, scroll)
+		if err != nil {
+			return err
+		}
+		cursor, scroll = idx, prompt.ScrollPosition()
+		switch {
+		case idx == len(items)+1:
+			return nil
+		case idx == len(items):
+			all := true
+			for _, sel := range selected {
+				all = all && sel
+			}
+			for i := range selected {
+				selected[i] = !all
+			}
+		default:
+			selected[idx] = !selected[idx]
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -11,6 +12,7 @@ import (
 var green = color.New(color.FgGreen).SprintFunc()
 var red = color.New(color.FgRed).SprintFunc()
 var faint = color.New(color.Faint).SprintFunc()
+var yellow = color.New(color.FgYellow).SprintFunc()
 
 type log int
 
@@ -20,18 +22,78 @@ const (
 	LOG_SUCCESS
 	LOG_FAILURE
 	LOG_STRICT_INFO
+	LOG_WARNING
 )
 
+func (s log) String() string {
+	statusToName := map[log]string{
+		LOG_INFO:        "info",
+		LOG_STRICT_INFO: "notice",
+		LOG_SUCCESS:     "success",
+		LOG_FAILURE:     "failure",
+		LOG_WARNING:     "warning",
+	}
+	return statusToName[s]
+}
+
+type OutputMode int
+
+const (
+	OUTPUT_TEXT OutputMode = iota
+	OUTPUT_QUIET
+	OUTPUT_JSON
+)
+
+var outputMode = OUTPUT_TEXT
+
+func SetOutputMode(m OutputMode) {
+	outputMode = m
+}
+
+// Interactive reports whether live output such as spinners and progress bars
+// may be drawn.
+func Interactive() bool {
+	return outputMode == OUTPUT_TEXT
+}
+
+func ParseOutputMode(s string) (OutputMode, error) {
+	nameToMode := map[string]OutputMode{
+		"text":  OUTPUT_TEXT,
+		"quiet": OUTPUT_QUIET,
+		"json":  OUTPUT_JSON,
+	}
+	m, ok := nameToMode[strings.ToLower(s)]
+	if !ok {
+		return OUTPUT_TEXT, fmt.Errorf("unknown output mode %q, use text, quiet or json", s)
+	}
+	return m, nil
+}
+
 func Logger(s log, msg string) {
+	if outputMode == OUTPUT_JSON {
+		b, _ := json.Marshal(struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		}{s.String(), msg})
+		fmt.Println(string(b))
+		return
+	}
+	if outputMode == OUTPUT_QUIET && (s == LOG_INFO || s == LOG_SUCCESS) {
+		return
+	}
+
 	statusToUnicode := map[log]string{
 		LOG_INFO:        "",
 		LOG_STRICT_INFO: green(">> "),
 		LOG_SUCCESS:     green("\U00002714 "),
 		LOG_FAILURE:     red("\U00002718 "),
+		LOG_WARNING:     yellow("! "),
 	}
 
 	if s == LOG_STRICT_INFO {
 		msg = green(msg)
+	} else if s == LOG_WARNING {
+		msg = yellow(msg)
 	} else if s != LOG_INFO && s != LOG_STRICT_INFO {
 		msg = strings.ToLower(msg)             // convert to lowercase
 		msg = strings.ReplaceAll(msg, ".", "") // remove punctuation
@@ -41,6 +103,21 @@ func Logger(s log, msg string) {
 	fmt.Printf("%s%s\n", statusToUnicode[s], msg)
 }
 
+// Detail prints a block of text such as a diff under title. It is shown in
+// quiet mode too since it is what the next prompt asks about.
+func Detail(title, body string) {
+	if outputMode == OUTPUT_JSON {
+		b, _ := json.Marshal(struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		}{LOG_INFO.String(), title, body})
+		fmt.Println(string(b))
+		return
+	}
+	fmt.Printf("\n%s\n%s", title, body)
+}
+
 func ErrorSymbol() string {
 	return red("\U00002718")
 }
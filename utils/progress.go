@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// counter lines sent by the remote on the sideband channel, e.g.
+// "Counting objects: 45% (9/20)" or "Enumerating objects: 20, done."
+var (
+	progressCounter = regexp.MustCompile(`^([A-Za-z ]+):\s+(?:\d+% \((\d+)/(\d+)\)|(\d+))`)
+	progressTotal   = regexp.MustCompile(`^Total (\d+)`)
+	// progressBytes is the size and speed git appends to transfer lines, e.g.
+	// "Writing objects: 100% (3/3), 1.20 MiB | 2.40 MiB/s, done."
+	progressBytes = regexp.MustCompile(`,\s+([\d.]+ [KMGT]?i?B)\s+\|\s+([\d.]+ [KMGT]?i?B/s)`)
+)
+
+// rateWindow is how far back the object throughput is measured.
+const rateWindow = 3 * time.Second
+
+// progressSample is the count of the active phase at some point in time.
+type progressSample struct {
+	at    time.Time
+	count int
+}
+
+type progressPhase struct {
+	current int
+	total   int
+}
+
+func (p *progressPhase) set(current, total int) {
+	p.current = current
+	p.total = total
+}
+
+func (p *progressPhase) String() string {
+	if p.total > 0 {
+		return fmt.Sprintf("%d/%d", p.current, p.total)
+	}
+	return strconv.Itoa(p.current)
+}
+
+// Progress renders the sideband progress of a fetch or push as a single
+// status line with the objects counted, compressed and transferred along with
+// the object throughput of the last seconds, and the byte throughput when the
+// remote reports it. Other remote messages are printed as they arrive.
+// Nothing is written in quiet or json output mode.
+type Progress struct {
+	mu         sync.Mutex
+	out        io.Writer
+	stage      string
+	tty        bool
+	now        func() time.Time
+	start      time.Time
+	partial    []byte
+	counted    progressPhase
+	compressed progressPhase
+	transfer   progressPhase
+	active     *progressPhase
+	samples    []progressSample
+	bytes      string
+	byteRate   string
+}
+
+func NewProgress(stage string) *Progress {
+	var out io.Writer = os.Stdout
+	if !Interactive() {
+		out = io.Discard
+	}
+	return &Progress{
+		out:   out,
+		stage: stage,
+		tty:   Interactive() && IsTerminal(),
+		now:   time.Now,
+		start: time.Now(),
+	}
+}
+
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexAny(p.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		p.handle(string(p.partial[:i]))
+		p.partial = p.partial[i+1:]
+	}
+	return len(b), nil
+}
+
+func (p *Progress) handle(msg string) {
+	msg = strings.TrimSpace(strings.TrimPrefix(msg, "remote:"))
+	if msg == "" {
+		return
+	}
+
+	if m := progressCounter.FindStringSubmatch(msg); m != nil {
+		current, total := atoi(m[2]), atoi(m[3])
+		if m[4] != "" {
+			current = atoi(m[4])
+		}
+		var phase *progressPhase
+		switch {
+		case strings.Contains(m[1], "Enumerating"), strings.Contains(m[1], "Counting"):
+			phase = &p.counted
+		case strings.Contains(m[1], "Compressing"):
+			phase = &p.compressed
+		case strings.Contains(m[1], "Receiving"), strings.Contains(m[1], "Writing"), strings.Contains(m[1], "Resolving"):
+			phase = &p.transfer
+		default:
+			p.printLine(msg)
+			return
+		}
+		if b := progressBytes.FindStringSubmatch(msg); b != nil {
+			p.bytes, p.byteRate = b[1], b[2]
+		}
+		p.update(phase, current, total)
+		return
+	}
+	if m := progressTotal.FindStringSubmatch(msg); m != nil {
+		p.update(&p.transfer, atoi(m[1]), 0)
+		return
+	}
+	p.printLine(msg)
+}
+
+// update sets the count of phase, which becomes the active one, and redraws
+// the status line.
+func (p *Progress) update(phase *progressPhase, current, total int) {
+	if phase != p.active {
+		p.samples = nil
+	}
+	p.active = phase
+	p.active.set(current, total)
+	now := p.now()
+	p.samples = append(p.samples, progressSample{at: now, count: current})
+	// two samples are kept to measure from even when updates are far apart
+	for len(p.samples) > 2 && now.Sub(p.samples[0].at) > rateWindow {
+		p.samples = p.samples[1:]
+	}
+	p.draw()
+}
+
+// rate returns the throughput of the active phase in objects per second,
+// over the last rateWindow.
+func (p *Progress) rate() float64 {
+	if len(p.samples) < 2 {
+		return 0
+	}
+	first, last := p.samples[0], p.samples[len(p.samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.count-first.count) / elapsed
+}
+
+func (p *Progress) status() string {
+	status := fmt.Sprintf("counted %s · compressed %s · transferred %s · %.0f obj/s",
+		&p.counted, &p.compressed, &p.transfer, p.rate())
+	if p.byteRate != "" {
+		status += fmt.Sprintf(" · %s at %s", p.bytes, p.byteRate)
+	}
+	return status
+}
+
+func (p *Progress) draw() {
+	if !p.tty {
+		return
+	}
+	elapsed := time.Since(p.start).Truncate(time.Second)
+	fmt.Fprintf(p.out, "\r\033[K%s %s %s", green("\U000021C5"), p.stage, faint(p.status(), " ", elapsed))
+}
+
+func (p *Progress) printLine(msg string) {
+	if p.tty {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+	fmt.Fprintf(p.out, "%s %s\n", faint(fmt.Sprintf("[%s]", p.stage)), msg)
+	if p.active != nil {
+		p.draw()
+	}
+}
+
+// Close clears the status line and prints a summary when the remote reported
+// any progress since the last one, so it is safe to call more than once.
+func (p *Progress) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.partial) > 0 {
+		p.handle(string(p.partial))
+		p.partial = nil
+	}
+	if p.tty {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+	if p.active != nil {
+		elapsed := time.Since(p.start).Truncate(time.Millisecond)
+		fmt.Fprintf(p.out, "%s %s\n", faint(fmt.Sprintf("[%s]", p.stage)), faint(p.status(), " in ", elapsed))
+		p.active = nil
+		p.samples = nil
+	}
+	return nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
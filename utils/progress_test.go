@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestProgressRate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		lines    []string
+		seconds  []int
+		wantRate float64
+		wantByte string
+	}{
+		{
+			name:     "single line",
+			lines:    []string{"Counting objects: 50% (10/20)"},
+			seconds:  []int{5},
+			wantRate: 0,
+		},
+		{
+			name:     "within the window",
+			lines:    []string{"Counting objects: 10% (10/100)", "Counting objects: 30% (30/100)"},
+			seconds:  []int{0, 2},
+			wantRate: 10,
+		},
+		{
+			name: "older samples dropped",
+			lines: []string{
+				"Counting objects: 0% (0/1000)",
+				"Counting objects: 10% (100/1000)",
+				"Counting objects: 20% (200/1000)",
+				"Counting objects: 80% (800/1000)",
+			},
+			seconds:  []int{0, 1, 10, 12},
+			wantRate: 300,
+		},
+		{
+			name:     "new phase starts over",
+			lines:    []string{"Counting objects: 100% (100/100)", "Compressing objects: 50% (5/10)"},
+			seconds:  []int{0, 1},
+			wantRate: 0,
+		},
+		{
+			name: "bytes reported by the remote",
+			lines: []string{
+				"Writing objects: 50% (1/2), 512.00 KiB | 1.00 MiB/s",
+				"Writing objects: 100% (2/2), 1.20 MiB | 2.40 MiB/s, done.",
+			},
+			seconds:  []int{0, 1},
+			wantRate: 1,
+			wantByte: "1.20 MiB at 2.40 MiB/s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := 0
+			p := &Progress{out: io.Discard, now: func() time.Time {
+				return start.Add(time.Duration(tt.seconds[i]) * time.Second)
+			}}
+			for ; i < len(tt.lines); i++ {
+				p.handle(tt.lines[i])
+			}
+			if got := p.rate(); got != tt.wantRate {
+				t.Errorf("rate() = %v, want %v", got, tt.wantRate)
+			}
+			if got := p.bytes + " at " + p.byteRate; tt.wantByte != "" && got != tt.wantByte {
+				t.Errorf("bytes = %q, want %q", got, tt.wantByte)
+			}
+		})
+	}
+}
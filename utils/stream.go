@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// IsTerminal reports whether stdout is attached to a terminal.
+func IsTerminal() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// Stream writes the live output of a stage line by line with the stage name as
+// prefix. On a terminal it keeps a spinner with the elapsed time below the
+// output, otherwise it prints plain lines. Nothing is written in quiet or json
+// output mode.
+type Stream struct {
+	mu      sync.Mutex
+	out     io.Writer
+	stage   string
+	tty     bool
+	start   time.Time
+	frame   int
+	partial []byte
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewStream(stage string) *Stream {
+	var out io.Writer = os.Stdout
+	if !Interactive() {
+		out = io.Discard
+	}
+	s := &Stream{
+		out:   out,
+		stage: stage,
+		tty:   Interactive() && IsTerminal(),
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+	if s.tty {
+		s.wg.Add(1)
+		go s.spin()
+	}
+	return s
+}
+
+func (s *Stream) spin() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.frame = (s.frame + 1) % len(spinnerFrames)
+			s.drawStatus()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// drawStatus redraws the spinner line, callers must hold s.mu.
+func (s *Stream) drawStatus() {
+	elapsed := time.Since(s.start).Truncate(time.Second)
+	fmt.Fprintf(s.out, "\r\033[K%s %s %s", green(spinnerFrames[s.frame]), s.stage, faint(elapsed))
+}
+
+// clearStatus removes the spinner line, callers must hold s.mu.
+func (s *Stream) clearStatus() {
+	fmt.Fprint(s.out, "\r\033[K")
+}
+
+func (s *Stream) writeLine(line []byte) {
+	fmt.Fprintf(s.out, "%s %s\n", faint(fmt.Sprintf("[%s]", s.stage)), bytes.TrimRight(line, "\r"))
+}
+
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.partial = append(s.partial, p...)
+	if s.tty {
+		s.clearStatus()
+	}
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.writeLine(s.partial[:i])
+		s.partial = s.partial[i+1:]
+	}
+	if s.tty {
+		s.drawStatus()
+	}
+	return len(p), nil
+}
+
+// Close flushes any unterminated line and stops the spinner.
+func (s *Stream) Close() error {
+	if s.tty {
+		close(s.done)
+		s.wg.Wait()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tty {
+		s.clearStatus()
+	}
+	if len(s.partial) > 0 {
+		s.writeLine(s.partial)
+		s.partial = nil
+	}
+	return nil
+}